@@ -2,17 +2,44 @@ package api
 
 import (
 	v1 "app/api/v1"
+	"app/lib/response"
+	_ "embed"
+	"encoding/json"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
 )
 
+// swaggerPage loads the assets of Swagger UI embedded by swaggerFiles next to it
+//
+//go:embed swagger.html
+var swaggerPage []byte
+
 func ApplyRoutes(app *gin.Engine) {
 	api := app.Group("api")
 	{
 		v1.ApplyRoutes(api)
+		// undocumented routes are reported by the tests, the document lists the others
+		doc, _ := v1.Document(app.Routes(), api.BasePath()+"/v1")
+		spec, err := json.Marshal(doc)
+		if err != nil {
+			log.Fatal(err)
+		}
+		api.GET("openapi.json", func(c *gin.Context) {
+			c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+		})
+		assets := http.StripPrefix(api.BasePath()+"/docs", http.FileServer(swaggerFiles.HTTP))
+		api.GET("docs/*filepath", func(c *gin.Context) {
+			if p := c.Param("filepath"); p == "/" || p == "/index.html" {
+				c.Data(http.StatusOK, "text/html; charset=utf-8", swaggerPage)
+				return
+			}
+			assets.ServeHTTP(c.Writer, c.Request)
+		})
 	}
-	app.NoRoute(func(c *gin.Context) {
-		c.AbortWithStatus(http.StatusNotFound)
-	})
+	app.HandleMethodNotAllowed = true
+	app.NoMethod(response.NoMethod(app.Routes))
+	app.NoRoute(response.NoRoute)
 }
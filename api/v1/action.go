@@ -2,10 +2,9 @@ package v1
 
 import (
 	"app/lib"
+	"app/lib/response"
 	"app/repository/dao"
 	"app/repository/dto"
-	"errors"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,12 +15,12 @@ func createAction(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	created, err := body.Create()
+	created, err := body.Create(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(created))
+	response.Created(c, location("public/action", created.ID), created)
 }
 
 func updateAction(c *gin.Context) {
@@ -31,39 +30,56 @@ func updateAction(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	saved, err := body.Save(id)
+	saved, err := body.Save(c.Request.Context(), id)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(saved))
+	response.OK(c, saved)
+}
+
+func patchAction(c *gin.Context) {
+	p, err := bindPatch(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var body dto.PatchAction
+	patched, err := body.Patch(c.Request.Context(), c.Param("id"), p)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, patched)
 }
 
 func deleteAction(c *gin.Context) {
 	id := c.Param("id")
-	exists, found := dao.ActionExists(id)
+	exists, found, err := dao.Actions.Exists(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 	if !exists {
-		_ = c.Error(errors.New("行为不存在"))
+		_ = c.Error(lib.ErrActionNotFound)
 		return
 	}
-	err := found.Delete()
+	err = found.Delete(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func action(c *gin.Context) {
 	id := c.Param("id")
-	found, err := dao.FindAction(id, map[string]interface{}{
-		"preload": []string{"Category"},
-	})
+	found, err := dao.Actions.Find(c.Request.Context(), id, dao.NewQuery().Preload("Category"))
 	if err != nil {
-		_ = c.Error(err)
+		_ = c.Error(dao.NotFoundAs(err, lib.ErrActionNotFound))
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(found))
+	response.OK(c, found)
 }
 
 func actions(c *gin.Context) {
@@ -72,15 +88,15 @@ func actions(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	rows, count, err := query.Find()
+	rows, count, err := query.Find(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(map[string]interface{}{
+	response.OK(c, map[string]interface{}{
 		"count": count,
 		"rows":  rows,
-	}))
+	})
 }
 
 func grantAction(c *gin.Context) {
@@ -89,12 +105,12 @@ func grantAction(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Grant()
+	err := body.Grant(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func revokeAction(c *gin.Context) {
@@ -103,12 +119,12 @@ func revokeAction(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Revoke()
+	err := body.Revoke(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func changeAction(c *gin.Context) {
@@ -117,10 +133,10 @@ func changeAction(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Change()
+	err := body.Change(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
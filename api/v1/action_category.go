@@ -2,10 +2,9 @@ package v1
 
 import (
 	"app/lib"
+	"app/lib/response"
 	"app/repository/dao"
 	"app/repository/dto"
-	"errors"
-	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -17,12 +16,12 @@ func createActionCategory(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	created, err := body.Create()
+	created, err := body.Create(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(created))
+	response.Created(c, location("public/action-category", created.ID), created)
 }
 
 func updateActionCategory(c *gin.Context) {
@@ -36,12 +35,32 @@ func updateActionCategory(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	saved, err := body.Save(uint(id))
+	saved, err := body.Save(c.Request.Context(), uint(id))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(saved))
+	response.OK(c, saved)
+}
+
+func patchActionCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	p, err := bindPatch(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var body dto.PatchActionCategory
+	patched, err := body.Patch(c.Request.Context(), uint(id), p)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, patched)
 }
 
 func deleteActionCategory(c *gin.Context) {
@@ -50,17 +69,21 @@ func deleteActionCategory(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	exists, found := dao.ActionCategoryExists(uint(id))
+	exists, found, err := dao.ActionCategories.Exists(c.Request.Context(), uint(id))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 	if !exists {
-		_ = c.Error(errors.New("权限分类不存在"))
+		_ = c.Error(lib.ErrCategoryNotFound)
 		return
 	}
-	err = found.Delete()
+	err = found.Delete(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func actionCategory(c *gin.Context) {
@@ -69,23 +92,19 @@ func actionCategory(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	found, err := dao.FindActionCategory(uint(id), map[string]interface{}{
-		"preload": []string{"Actions"},
-	})
+	found, err := dao.ActionCategories.Find(c.Request.Context(), uint(id), dao.NewQuery().Preload("Actions"))
 	if err != nil {
-		_ = c.Error(err)
+		_ = c.Error(dao.NotFoundAs(err, lib.ErrCategoryNotFound))
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(found))
+	response.OK(c, found)
 }
 
 func actionCategories(c *gin.Context) {
-	rows, err := dao.FindActionCategories(map[string]interface{}{
-		"preload": []string{"Actions"},
-	})
+	rows, err := dao.ActionCategories.FindAll(c.Request.Context(), dao.NewQuery().Preload("Actions"))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(rows))
+	response.OK(c, rows)
 }
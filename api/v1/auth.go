@@ -3,11 +3,12 @@ package v1
 import (
 	"app/lib"
 	"app/lib/config"
+	"app/lib/response"
 	"app/repository/dao"
 	"app/repository/dto"
-	"errors"
-	"net/http"
+	"context"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,31 +19,23 @@ func register(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	exists, _ := dao.FindByUsername(body.Username)
-	if exists {
-		_ = c.Error(errors.New("用户已存在"))
-		return
-	}
 	defaultRoleID, err := strconv.Atoi(config.App.DefaultRole)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	created, err := body.Create(uint(defaultRoleID))
+	created, err := body.Create(c.Request.Context(), uint(defaultRoleID))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	token, err := lib.GenerateJWTToken(config.App.JWTSecret, map[string]interface{}{
-		"id": created.ID, "username": created.Username, "roleID": defaultRoleID,
-	})
+	tokens, err := issueTokens(c.Request.Context(), created)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(map[string]interface{}{
-		"user": created, "token": token,
-	}))
+	tokens["user"] = created
+	response.Created(c, location("public/user", created.ID), tokens)
 }
 
 func login(c *gin.Context) {
@@ -56,25 +49,90 @@ func login(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	found, err := body.Login(uint(defaultRoleID))
+	found, err := body.Login(c.Request.Context(), uint(defaultRoleID))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 	if !found.IsActived {
-		_ = c.Error(errors.New("用户未激活"))
+		_ = c.Error(lib.ErrUserInactive)
 		return
 	}
-	token, err := lib.GenerateJWTToken(config.App.JWTSecret, map[string]interface{}{
-		"id": found.ID, "username": found.Username, "roleID": found.RoleID,
-	})
+	tokens, err := issueTokens(c.Request.Context(), found)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(map[string]interface{}{
-		"user": found, "token": token,
-	}))
+	tokens["user"] = found
+	response.OK(c, tokens)
+}
+
+func refresh(c *gin.Context) {
+	var body dto.RefreshAuth
+	if err := c.ShouldBind(&body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	found, refreshToken, err := body.Refresh(c.Request.Context(), config.App.RefreshTokenTTL)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	token, err := signAccessToken(found)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, map[string]interface{}{
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(config.App.AccessTokenTTL.Seconds()),
+	})
+}
+
+func logout(c *gin.Context) {
+	var body dto.Logout
+	if err := c.ShouldBind(&body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	auth := c.GetStringMap("auth")
+	id := auth["id"].(string)
+	if err := body.Logout(c.Request.Context(), id); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	claims := c.GetStringMap("claims")
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if err := lib.Revocations.Revoke(c.Request.Context(), jti, time.Unix(int64(exp), 0)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.NoContent(c)
+}
+
+func signAccessToken(user dao.User) (string, error) {
+	return lib.GenerateJWTToken(config.App.JWTSecret, map[string]interface{}{
+		"id": user.ID, "username": user.Username, "roleID": user.RoleID, "locale": user.Locale,
+	}, config.App.AccessTokenTTL)
+}
+
+// issueTokens signs an access token for user and starts a new refresh token family
+func issueTokens(ctx context.Context, user dao.User) (map[string]interface{}, error) {
+	token, err := signAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := dto.IssueRefreshToken(ctx, user.ID, config.App.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(config.App.AccessTokenTTL.Seconds()),
+	}, nil
 }
 
 func changePassword(c *gin.Context) {
@@ -85,12 +143,30 @@ func changePassword(c *gin.Context) {
 	}
 	auth := c.GetStringMap("auth")
 	id := auth["id"].(string)
-	updated, err := body.ChangePassword(id)
+	updated, err := body.ChangePassword(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, updated)
+}
+
+// changeLocale stores the locale preferred by the signed in user, access tokens
+// signed from now on carry it so it applies after the next refresh
+func changeLocale(c *gin.Context) {
+	var body dto.ChangeLocale
+	if err := c.ShouldBind(&body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	auth := c.GetStringMap("auth")
+	id := auth["id"].(string)
+	updated, err := body.ChangeLocale(c.Request.Context(), id)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(updated))
+	response.OK(c, updated)
 }
 
 func resetPassword(c *gin.Context) {
@@ -100,27 +176,23 @@ func resetPassword(c *gin.Context) {
 		return
 	}
 	id := c.Param("id")
-	updated, err := body.ResetPassword(id)
+	updated, err := body.ResetPassword(c.Request.Context(), id)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(updated))
+	response.OK(c, updated)
 }
 
 func me(c *gin.Context) {
 	auth := c.GetStringMap("auth")
 	id := auth["id"].(string)
-	user, err := dao.FindUser(id, map[string]interface{}{
-		"preload": []string{"Group"},
-	})
+	user, err := dao.Users.Find(c.Request.Context(), id, dao.NewQuery().Preload("Group"))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	role, err := dao.FindRole(*user.RoleID, map[string]interface{}{
-		"preload": []string{"Actions"},
-	})
+	role, err := dao.Roles.Find(c.Request.Context(), *user.RoleID, dao.NewQuery().Preload("Actions"))
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -130,14 +202,12 @@ func me(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	defaultRole, err := dao.FindRole(uint(defaultRoleID), map[string]interface{}{
-		"preload": []string{"Actions"},
-	})
+	defaultRole, err := dao.Roles.Find(c.Request.Context(), uint(defaultRoleID), dao.NewQuery().Preload("Actions"))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(map[string]interface{}{
+	response.OK(c, map[string]interface{}{
 		"user": user, "currentRole": role, "defaultRole": defaultRole,
-	}))
+	})
 }
@@ -0,0 +1,200 @@
+package v1
+
+import (
+	"app/lib"
+	"app/lib/openapi"
+	"app/middleware"
+	"app/repository/dao"
+	"app/repository/dto"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// replies the handlers write as maps, declared to document them
+type tokensReply struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+type signInReply struct {
+	tokensReply
+	User dao.User `json:"user"`
+}
+
+type meReply struct {
+	User        dao.User `json:"user"`
+	CurrentRole dao.Role `json:"currentRole"`
+	DefaultRole dao.Role `json:"defaultRole"`
+}
+
+type usersPage struct {
+	Count int64      `json:"count"`
+	Rows  []dao.User `json:"rows"`
+}
+
+type groupsPage struct {
+	Count int64       `json:"count"`
+	Rows  []dao.Group `json:"rows"`
+}
+
+type rolesPage struct {
+	Count int64      `json:"count"`
+	Rows  []dao.Role `json:"rows"`
+}
+
+type actionsPage struct {
+	Count int64        `json:"count"`
+	Rows  []dao.Action `json:"rows"`
+}
+
+type conversationPage struct {
+	Rows       []dao.Message `json:"rows"`
+	NextCursor string        `json:"nextCursor"`
+}
+
+type unreadCount struct {
+	SenderID string `json:"sender_id"`
+	Count    int64  `json:"count"`
+}
+
+type readCount struct {
+	Count int64 `json:"count"`
+}
+
+type pong struct {
+	Message string `json:"message"`
+}
+
+// docs describes every "METHOD route" for the openapi document, whether a route is
+// public and the action it requires come from the JWT rules and permissions
+var docs = map[string]openapi.Route{
+	"GET ping": {Tag: "auth", Summary: "Check the service is up", Data: pong{}},
+
+	"POST public/register": {Tag: "auth", Summary: "Register a user with the default role and sign in", Body: dto.RegisterUser{}, Data: signInReply{}, Status: http.StatusCreated,
+		Errors: []*lib.AppError{lib.ErrUserExists, lib.ErrRoleNotFound}},
+	"POST public/login": {Tag: "auth", Summary: "Sign in with username and password", Body: dto.LoginUser{}, Data: signInReply{},
+		Errors: []*lib.AppError{lib.ErrUserNotFound, lib.ErrPasswordWrong, lib.ErrUserInactive}},
+	"POST public/refresh": {Tag: "auth", Summary: "Rotate a refresh token for a new access token", Body: dto.RefreshAuth{}, Data: tokensReply{},
+		Errors: []*lib.AppError{lib.ErrRefreshTokenMissing, lib.ErrRefreshTokenInvalid, lib.ErrRefreshTokenReused, lib.ErrRefreshTokenExpired, lib.ErrUserNotFound, lib.ErrUserInactive}},
+	"POST logout": {Tag: "auth", Summary: "Revoke the access token and the refresh token family", Body: dto.Logout{},
+		Errors: []*lib.AppError{lib.ErrRefreshTokenMissing}},
+	"POST change/password": {Tag: "auth", Summary: "Change the password of the signed in user and sign out everywhere", Body: dto.ChangePassword{}, Data: dao.User{},
+		Errors: []*lib.AppError{lib.ErrUserNotFound, lib.ErrOldPasswordWrong, lib.ErrPasswordMismatch}},
+	"POST change/locale": {Tag: "auth", Summary: "Set the locale replied to the signed in user, empty follows Accept-Language", Body: dto.ChangeLocale{}, Data: dao.User{},
+		Errors: []*lib.AppError{lib.ErrUserNotFound}},
+	"POST reset/:id/password": {Tag: "auth", Summary: "Reset the password of a user and sign them out everywhere", Body: dto.ResetPassword{}, Data: dao.User{},
+		Errors: []*lib.AppError{lib.ErrUserNotFound, lib.ErrPasswordMismatch}},
+	"GET me": {Tag: "auth", Summary: "The signed in user with their current and the default role", Data: meReply{}},
+
+	"GET public/message": {Tag: "message", Summary: "Open the websocket of the user of the token query parameter",
+		Description: "Upgrades to a websocket, events failing reply the error envelope in data. Pass the access token as `?token=`. Members leaving a group or of a deleted group get an `unsubscribed` event of its channel.", Status: http.StatusSwitchingProtocols,
+		Errors: []*lib.AppError{lib.ErrAuthInvalid, lib.ErrTokenInvalid, lib.ErrTokenRevoked}},
+	"GET message/unread": {Tag: "message", Summary: "Count the unread messages by sender", Data: []unreadCount{}},
+	"GET message/user/:userID": {Tag: "message", Summary: "Page through the conversation with a user, newest first", Query: dto.QueryConversation{}, Data: conversationPage{},
+		Errors: []*lib.AppError{lib.ErrMessageNotFound}},
+	"POST message/read": {Tag: "message", Summary: "Mark the messages of a sender read", Body: dto.ReadMessage{}, Data: readCount{}},
+
+	"GET public/user":     {Tag: "user", Summary: "List users", Query: dto.QueryUser{}, Data: usersPage{}},
+	"GET public/user/:id": {Tag: "user", Summary: "Get a user with their group and role", Data: dao.User{}, Errors: []*lib.AppError{lib.ErrUserNotFound}},
+	"PUT user/:id": {Tag: "user", Summary: "Update a user, users update their own account without the action", Body: dto.UpdateUser{}, Data: dao.User{},
+		Errors: []*lib.AppError{lib.ErrUserNotFound}},
+	"PATCH user/:id": {Tag: "user", Summary: "Patch a user, fields can be set to false or cleared. Users patch their own account without the action", Patch: dto.PatchUser{}, Data: dao.User{},
+		Errors: []*lib.AppError{lib.ErrUserNotFound}},
+	"DELETE user/:id":        {Tag: "user", Summary: "Delete a user", Errors: []*lib.AppError{lib.ErrUserNotFound}},
+	"POST active/user":       {Tag: "user", Summary: "Activate a user", Body: dto.ToggleUserActive{}, Errors: []*lib.AppError{lib.ErrUserNotFound}},
+	"POST deactive/user":     {Tag: "user", Summary: "Deactivate a user and sign them out everywhere", Body: dto.ToggleUserActive{}, Errors: []*lib.AppError{lib.ErrUserNotFound}},
+	"POST follow/user":       {Tag: "social", Summary: "Follow a user", Body: dto.ToggleFollow{}, Data: dao.User{}, Errors: []*lib.AppError{lib.ErrNotFound}},
+	"DELETE follow/user":     {Tag: "social", Summary: "Unfollow a user", Body: dto.ToggleFollow{}, Data: dao.User{}, Errors: []*lib.AppError{lib.ErrNotFound}},
+	"GET user/:id/fans":      {Tag: "social", Summary: "A user with their fans", Data: dao.User{}},
+	"GET user/:id/following": {Tag: "social", Summary: "A user with the users they follow", Data: dao.User{}},
+
+	"POST group": {Tag: "group", Summary: "Create a group owned by the signed in user", Body: dto.NewGroup{}, Data: dao.Group{}, Status: http.StatusCreated,
+		Errors: []*lib.AppError{lib.ErrGroupExists, lib.ErrAlreadyInGroup}},
+	"GET public/group":     {Tag: "group", Summary: "List groups", Query: dto.QueryGroup{}, Data: groupsPage{}},
+	"GET public/group/:id": {Tag: "group", Summary: "Get a group with its owner and users", Data: dao.Group{}, Errors: []*lib.AppError{lib.ErrGroupNotFound}},
+	"PUT group/:id": {Tag: "group", Summary: "Update a group managed by the signed in user", Body: dto.UpdateGroup{}, Data: dao.Group{},
+		Errors: []*lib.AppError{lib.ErrGroupNotFound, lib.ErrGroupDenied}},
+	"PATCH group/:id": {Tag: "group", Summary: "Patch a group managed by the signed in user", Patch: dto.PatchGroup{}, Data: dao.Group{},
+		Errors: []*lib.AppError{lib.ErrGroupNotFound, lib.ErrGroupDenied}},
+	"DELETE group": {Tag: "group", Summary: "Delete a group managed by the signed in user", Body: dto.DeleteGroup{},
+		Errors: []*lib.AppError{lib.ErrGroupNotFound, lib.ErrGroupDenied}},
+	"POST group/user": {Tag: "group", Summary: "Add users without a group to a group managed by the signed in user", Body: dto.IOGroup{}, Data: dao.Group{},
+		Errors: []*lib.AppError{lib.ErrGroupNotFound, lib.ErrGroupDenied}},
+	"DELETE group/user": {Tag: "group", Summary: "Leave a group or remove a user from a managed group", Body: dto.IOGroup{}, Data: dao.Group{},
+		Errors: []*lib.AppError{lib.ErrGroupNotFound, lib.ErrGroupDenied, lib.ErrGroupOwnerLeave}},
+
+	"POST role": {Tag: "role", Summary: "Create a role", Body: dto.NewRole{}, Data: dao.Role{}, Status: http.StatusCreated,
+		Errors: []*lib.AppError{lib.ErrRoleExists, lib.ErrConflict}},
+	"GET public/role": {Tag: "role", Summary: "List roles", Query: dto.QueryRole{}, Data: rolesPage{}},
+	"PUT role/:id": {Tag: "role", Summary: "Update a role", Body: dto.UpdateRole{}, Data: dao.Role{},
+		Errors: []*lib.AppError{lib.ErrRoleNotFound, lib.ErrConflict}},
+	"PATCH role/:id": {Tag: "role", Summary: "Patch a role", Patch: dto.PatchRole{}, Data: dao.Role{},
+		Errors: []*lib.AppError{lib.ErrRoleNotFound, lib.ErrConflict}},
+	"DELETE role/:id":     {Tag: "role", Summary: "Delete a role", Errors: []*lib.AppError{lib.ErrBadRequest, lib.ErrRoleNotFound}},
+	"GET public/role/:id": {Tag: "role", Summary: "Get a role with its actions", Data: dao.Role{}, Errors: []*lib.AppError{lib.ErrBadRequest, lib.ErrRoleNotFound}},
+	"POST user/role":      {Tag: "role", Summary: "Grant a role to a user", Body: dto.OPRole{}, Errors: []*lib.AppError{lib.ErrUserNotFound, lib.ErrRoleNotFound}},
+	"DELETE user/role":    {Tag: "role", Summary: "Revoke the role of a user", Body: dto.OPRole{}, Errors: []*lib.AppError{lib.ErrUserNotFound, lib.ErrRoleNotFound}},
+	"PUT user/role":       {Tag: "role", Summary: "Change the role of a user", Body: dto.OPRole{}, Errors: []*lib.AppError{lib.ErrUserNotFound, lib.ErrRoleNotFound}},
+	"POST active/role":    {Tag: "role", Summary: "Activate a role", Body: dto.ToggleRoleActive{}, Errors: []*lib.AppError{lib.ErrRoleNotFound}},
+	"DELETE active/role":  {Tag: "role", Summary: "Deactivate a role", Body: dto.ToggleRoleActive{}, Errors: []*lib.AppError{lib.ErrRoleNotFound}},
+
+	"POST action-category": {Tag: "action", Summary: "Create an action category", Body: dto.NewActionCategory{}, Data: dao.ActionCategory{}, Status: http.StatusCreated},
+	"PUT action-category/:id": {Tag: "action", Summary: "Update an action category", Body: dto.UpdateActionCategory{}, Data: dao.ActionCategory{},
+		Errors: []*lib.AppError{lib.ErrCategoryNotFound}},
+	"PATCH action-category/:id": {Tag: "action", Summary: "Patch an action category", Patch: dto.PatchActionCategory{}, Data: dao.ActionCategory{},
+		Errors: []*lib.AppError{lib.ErrCategoryNotFound}},
+	"GET public/action-category/:id": {Tag: "action", Summary: "Get an action category with its actions", Data: dao.ActionCategory{},
+		Errors: []*lib.AppError{lib.ErrBadRequest, lib.ErrCategoryNotFound}},
+	"GET public/action-category": {Tag: "action", Summary: "List action categories with their actions", Data: []dao.ActionCategory{}},
+	"DELETE action-category/:id": {Tag: "action", Summary: "Delete an action category", Errors: []*lib.AppError{lib.ErrBadRequest, lib.ErrCategoryNotFound}},
+
+	"POST action": {Tag: "action", Summary: "Create an action", Body: dto.NewAction{}, Data: dao.Action{}, Status: http.StatusCreated,
+		Errors: []*lib.AppError{lib.ErrCategoryNotFound}},
+	"GET public/action": {Tag: "action", Summary: "List actions", Query: dto.QueryAction{}, Data: actionsPage{}},
+	"PUT action/:id": {Tag: "action", Summary: "Update an action", Body: dto.UpdateAction{}, Data: dao.Action{},
+		Errors: []*lib.AppError{lib.ErrActionNotFound, lib.ErrCategoryNotFound}},
+	"PATCH action/:id": {Tag: "action", Summary: "Patch an action", Patch: dto.PatchAction{}, Data: dao.Action{},
+		Errors: []*lib.AppError{lib.ErrActionNotFound, lib.ErrCategoryNotFound}},
+	"DELETE action/:id":     {Tag: "action", Summary: "Delete an action", Errors: []*lib.AppError{lib.ErrActionNotFound}},
+	"GET public/action/:id": {Tag: "action", Summary: "Get an action", Data: dao.Action{}, Errors: []*lib.AppError{lib.ErrActionNotFound}},
+	"POST role/action":      {Tag: "action", Summary: "Grant an action to a role", Body: dto.OPAction{}, Errors: []*lib.AppError{lib.ErrRoleNotFound, lib.ErrActionNotFound}},
+	"DELETE role/action":    {Tag: "action", Summary: "Revoke an action of a role", Body: dto.OPAction{}, Errors: []*lib.AppError{lib.ErrRoleNotFound, lib.ErrActionNotFound}},
+	"PUT role/action":       {Tag: "action", Summary: "Replace the actions of a role", Body: dto.OPAction{}, Errors: []*lib.AppError{lib.ErrRoleNotFound, lib.ErrActionNotFound}},
+}
+
+// Document builds the openapi document of the routes under basePath, the problems
+// list routes without docs and docs without a route
+func Document(routes gin.RoutesInfo, basePath string) (*openapi.Document, []string) {
+	doc := openapi.New("Gin API Starter", "v1", basePath)
+	var problems []string
+	documented := make(map[string]bool)
+	public := unlessRules(basePath)
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Path, basePath+"/") {
+			continue
+		}
+		route := strings.TrimPrefix(r.Path, basePath+"/")
+		key := r.Method + " " + route
+		spec, ok := docs[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s has no docs", r.Method, r.Path))
+			continue
+		}
+		documented[key] = true
+		spec.Public, _ = middleware.MatchRules(public, r.Path, r.Method)
+		spec.Action = permissions[key]
+		doc.AddRoute(r.Method, "/"+route, spec)
+	}
+	for key := range docs {
+		if !documented[key] {
+			problems = append(problems, fmt.Sprintf("docs of %s match no route", key))
+		}
+	}
+	sort.Strings(problems)
+	return doc, problems
+}
@@ -0,0 +1,280 @@
+package v1
+
+import (
+	"app/lib"
+	"app/lib/config"
+	"app/lib/response"
+	"app/lib/ws"
+	"app/middleware"
+	"app/repository/dao"
+	"app/repository/dto"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isGroupManager reports whether the caller owns the group or may manage every group
+func isGroupManager(c *gin.Context, group dao.Group) (bool, error) {
+	auth := c.GetStringMap("auth")
+	if id, _ := auth["id"].(string); id != "" && id == group.OwnerID {
+		return true, nil
+	}
+	return middleware.Permitted(c, "GROUP_WRITE")
+}
+
+func createGroup(c *gin.Context) {
+	var body dto.NewGroup
+	if err := c.ShouldBind(&body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	auth := c.GetStringMap("auth")
+	id := auth["id"].(string)
+	me, err := dao.Users.Find(c.Request.Context(), id, nil)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if me.GroupID != nil {
+		_ = c.Error(lib.ErrAlreadyInGroup)
+		return
+	}
+	exists, _, err := dao.Groups.ExistsBy(c.Request.Context(), "name", body.Name)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if exists {
+		_ = c.Error(lib.ErrGroupExists)
+		return
+	}
+	groupAdminRoleID, err := strconv.Atoi(config.App.GroupAdminRole)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	groupAdminRole, err := dao.Roles.Find(c.Request.Context(), uint(groupAdminRoleID), nil)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	created, err := body.Create(c.Request.Context(), &me, &groupAdminRole)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.Created(c, location("public/group", created.ID), created)
+}
+
+func updateGroup(c *gin.Context) {
+	id := c.Param("id")
+	var body dto.UpdateGroup
+	if err := c.ShouldBind(&body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	exists, found, err := dao.Groups.Exists(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !exists {
+		_ = c.Error(lib.ErrGroupNotFound)
+		return
+	}
+	permitted, err := isGroupManager(c, found)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !permitted {
+		_ = c.Error(lib.ErrGroupDenied)
+		return
+	}
+	saved, err := body.Save(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, saved)
+}
+
+func patchGroup(c *gin.Context) {
+	p, err := bindPatch(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	exists, found, err := dao.Groups.Exists(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !exists {
+		_ = c.Error(lib.ErrGroupNotFound)
+		return
+	}
+	permitted, err := isGroupManager(c, found)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !permitted {
+		_ = c.Error(lib.ErrGroupDenied)
+		return
+	}
+	var body dto.PatchGroup
+	patched, err := body.Patch(c.Request.Context(), found, p)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, patched)
+}
+
+func deleteGroups(c *gin.Context) {
+	var body dto.DeleteGroup
+	if err := c.ShouldBind(&body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	rows, err := dao.Groups.FindAll(c.Request.Context(), dao.NewQuery().WhereIDs(strings.Split(body.ID, ",")))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if len(rows) == 0 {
+		_ = c.Error(lib.ErrGroupNotFound)
+		return
+	}
+	for _, row := range rows {
+		permitted, err := isGroupManager(c, row)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if !permitted {
+			_ = c.Error(lib.ErrGroupDenied)
+			return
+		}
+	}
+	defaultRoleID, err := strconv.Atoi(config.App.DefaultRole)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := body.Delete(c.Request.Context(), uint(defaultRoleID)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	for _, row := range rows {
+		ws.WebsocketManager.CloseChannel(groupChannel(row.ID))
+	}
+	response.NoContent(c)
+}
+
+func group(c *gin.Context) {
+	id := c.Param("id")
+	found, err := dao.Groups.Find(c.Request.Context(), id, dao.NewQuery().Preload("Owner").Preload("Users"))
+	if err != nil {
+		_ = c.Error(dao.NotFoundAs(err, lib.ErrGroupNotFound))
+		return
+	}
+	response.OK(c, found)
+}
+
+func groups(c *gin.Context) {
+	var query dto.QueryGroup
+	if err := c.ShouldBind(&query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	rows, count, err := query.Find(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, map[string]interface{}{
+		"count": count,
+		"rows":  rows,
+	})
+}
+
+func joinGroup(c *gin.Context) {
+	var body dto.IOGroup
+	if err := c.ShouldBind(&body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	exists, found, err := dao.Groups.Exists(c.Request.Context(), body.GroupID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !exists {
+		_ = c.Error(lib.ErrGroupNotFound)
+		return
+	}
+	permitted, err := isGroupManager(c, found)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !permitted {
+		_ = c.Error(lib.ErrGroupDenied)
+		return
+	}
+	joined, err := body.In(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, joined)
+}
+
+func leaveGroup(c *gin.Context) {
+	var body dto.IOGroup
+	if err := c.ShouldBind(&body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	exists, found, err := dao.Groups.Exists(c.Request.Context(), body.GroupID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !exists {
+		_ = c.Error(lib.ErrGroupNotFound)
+		return
+	}
+	auth := c.GetStringMap("auth")
+	id := auth["id"].(string)
+	// members are always allowed to leave by themselves
+	permitted := body.UserID == id
+	if !permitted {
+		var err error
+		permitted, err = isGroupManager(c, found)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+	if !permitted {
+		_ = c.Error(lib.ErrGroupDenied)
+		return
+	}
+	defaultRoleID, err := strconv.Atoi(config.App.DefaultRole)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	left, err := body.Out(c.Request.Context(), uint(defaultRoleID))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	// the channel of the group was only authorized when they subscribed
+	ws.WebsocketManager.Evict(groupChannel(left.ID), strings.Split(body.UserID, ",")...)
+	response.OK(c, left)
+}
@@ -1,28 +1,47 @@
 package v1
 
 import (
+	"app/lib"
+	"app/lib/config"
+	"app/lib/response"
 	"app/lib/ws"
+	"app/middleware"
+	"app/repository/dao"
+	"app/repository/dto"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/websocket"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
-}
-
 func messager(c *gin.Context) {
-	unsafeConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	token, err := middleware.VerifyToken(c.Request.Context(), c.Query("token"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	auth, _ := token["auth"].(map[string]interface{})
+	id, _ := auth["id"].(string)
+	if id == "" {
+		_ = c.Error(lib.ErrAuthInvalid)
+		return
+	}
+	middleware.PreferLocale(c, auth)
+	// events fail in the locale of the request which opened the connection
+	ctx := c.Request.Context()
+	fail := func(err error) interface{} {
+		return response.Envelope(lib.TranslatorFrom(ctx), middleware.ResolveError(err))
+	}
+	unsafeConn, err := ws.Upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	token := c.Query("token")
-	client := ws.WebsocketManager.RegisterConn(token, unsafeConn)
+	client := ws.WebsocketManager.RegisterConn(id, unsafeConn)
 	go client.ReadPump(func(raw []byte) error {
 		message := ws.Message{}
 		if err := json.Unmarshal(raw, &message); err != nil {
@@ -32,8 +51,8 @@ func messager(c *gin.Context) {
 		case ws.StatusEvent:
 			data, ok := message.Data.([]interface{})
 			if !ok {
-				client.Conn.WriteJSON(&ws.Message{Event: ws.StatusFailEvent, Data: "data 类型不正确"})
-				return errors.New("unsupported data type")
+				client.Send(&ws.Message{Event: ws.StatusFailEvent, Data: fail(lib.ErrMessageDataInvalid)})
+				return lib.ErrMessageDataInvalid
 			}
 			result := make(map[string]bool)
 			for _, v := range data {
@@ -44,9 +63,140 @@ func messager(c *gin.Context) {
 					result[k] = false
 				}
 			}
-			client.Conn.WriteJSON(&ws.Message{Event: ws.StatusResultEvent, Data: result})
+			client.Send(&ws.Message{Event: ws.StatusResultEvent, Data: result})
+		case ws.SubscribeEvent:
+			if err := ws.WebsocketManager.Subscribe(client, message.Channel); err != nil {
+				client.Send(&ws.Message{Event: ws.SubscribeFailEvent, Channel: message.Channel, Data: fail(err)})
+				return err
+			}
+			client.Send(&ws.Message{Event: ws.SubscribedEvent, Channel: message.Channel})
+		case ws.UnsubscribeEvent:
+			ws.WebsocketManager.Unsubscribe(client, message.Channel)
+			client.Send(&ws.Message{Event: ws.UnsubscribedEvent, Channel: message.Channel})
+		case ws.PublishEvent:
+			if err := ws.WebsocketManager.PublishFrom(client, message.Channel, message.Data); err != nil {
+				client.Send(&ws.Message{Event: ws.PublishFailEvent, Channel: message.Channel, Data: fail(err)})
+				return err
+			}
+		case ws.MessageEvent:
+			ctx, cancel := queryContext()
+			created, err := sendDirectMessage(ctx, client.Key, message.Data)
+			cancel()
+			if err != nil {
+				client.Send(&ws.Message{Event: ws.MessageFailEvent, Data: fail(err)})
+				return err
+			}
+			// every connection of the sender learns about the stored message
+			ws.WebsocketManager.SendTo(&ws.Message{Event: ws.MessageSentEvent, Data: created}, client.Key)
+			if ws.WebsocketManager.Contains(created.RecipientID) {
+				ws.WebsocketManager.SendTo(&ws.Message{Event: ws.MessageEvent, From: client.Key, Data: created}, created.RecipientID)
+			}
 		}
 		return nil
 	})
 	c.Status(http.StatusOK)
 }
+
+// queryContext bounds the queries of a websocket frame, which outlives the request that upgraded it
+func queryContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), config.App.QueryTimeout)
+}
+
+func sendDirectMessage(ctx context.Context, from string, data interface{}) (dao.Message, error) {
+	var body dto.NewMessage
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return dao.Message{}, err
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return dao.Message{}, lib.ErrMessageDataInvalid
+	}
+	if err := binding.Validator.ValidateStruct(&body); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			return dao.Message{}, lib.ErrValidation.WithDetails(errs)
+		}
+		return dao.Message{}, err
+	}
+	return body.Send(ctx, from)
+}
+
+func conversation(c *gin.Context) {
+	var query dto.QueryConversation
+	if err := c.ShouldBind(&query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	auth := c.GetStringMap("auth")
+	id := auth["id"].(string)
+	rows, next, err := query.Find(c.Request.Context(), id, c.Param("userID"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, map[string]interface{}{
+		"rows":       rows,
+		"nextCursor": next,
+	})
+}
+
+func unreadMessages(c *gin.Context) {
+	auth := c.GetStringMap("auth")
+	id := auth["id"].(string)
+	rows, err := dao.CountUnreadMessages(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, rows)
+}
+
+func readMessages(c *gin.Context) {
+	var body dto.ReadMessage
+	if err := c.ShouldBind(&body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	auth := c.GetStringMap("auth")
+	id := auth["id"].(string)
+	count, err := body.Read(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, map[string]interface{}{
+		"count": count,
+	})
+}
+
+const groupChannelKind = "group"
+
+// groupChannel is the channel of the members of the group id, its subscribers are
+// evicted when they leave the group or the group is deleted
+func groupChannel(id string) string {
+	return groupChannelKind + ":" + id
+}
+
+// authorizeChannels lets users listen to their own channel and talk in the channel of their group
+func authorizeChannels() {
+	ws.WebsocketManager.Authorize("user", func(key string, id string, event ws.Event) error {
+		if event != ws.SubscribeEvent {
+			return lib.ErrUserChannelPublish
+		}
+		if key != id {
+			return lib.ErrChannelDenied
+		}
+		return nil
+	})
+	ws.WebsocketManager.Authorize(groupChannelKind, func(key string, id string, event ws.Event) error {
+		ctx, cancel := queryContext()
+		defer cancel()
+		user, err := dao.Users.Find(ctx, key, nil)
+		if err != nil {
+			return err
+		}
+		if user.GroupID == nil || *user.GroupID != id {
+			return lib.ErrChannelDenied
+		}
+		return nil
+	})
+}
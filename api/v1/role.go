@@ -2,10 +2,9 @@ package v1
 
 import (
 	"app/lib"
+	"app/lib/response"
 	"app/repository/dao"
 	"app/repository/dto"
-	"errors"
-	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -17,17 +16,21 @@ func createRole(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	exists, _ := dao.RoleExistsByName(body.Name)
+	exists, _, err := dao.Roles.ExistsBy(c.Request.Context(), "name", body.Name)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 	if exists {
-		_ = c.Error(errors.New("角色已存在"))
+		_ = c.Error(lib.ErrRoleExists)
 		return
 	}
-	created, err := body.Create()
+	created, err := body.Create(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(created))
+	response.Created(c, location("public/role", created.ID), created)
 }
 
 func updateRole(c *gin.Context) {
@@ -41,12 +44,32 @@ func updateRole(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	saved, err := body.Save(uint(id))
+	saved, err := body.Save(c.Request.Context(), uint(id))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, saved)
+}
+
+func patchRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	p, err := bindPatch(c)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(saved))
+	var body dto.PatchRole
+	patched, err := body.Patch(c.Request.Context(), uint(id), p)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, patched)
 }
 
 func deleteRole(c *gin.Context) {
@@ -55,17 +78,21 @@ func deleteRole(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	exists, found := dao.RoleExists(uint(id))
+	exists, found, err := dao.Roles.Exists(c.Request.Context(), uint(id))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 	if !exists {
-		_ = c.Error(errors.New("角色不存在"))
+		_ = c.Error(lib.ErrRoleNotFound)
 		return
 	}
-	err = found.Delete()
+	err = found.Delete(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func role(c *gin.Context) {
@@ -74,14 +101,12 @@ func role(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	found, err := dao.FindRole(uint(id), map[string]interface{}{
-		"preload": []string{"Users", "Actions", "Actions.Category"},
-	})
+	found, err := dao.Roles.Find(c.Request.Context(), uint(id), dao.NewQuery().Preload("Users").Preload("Actions").Preload("Actions.Category"))
 	if err != nil {
-		_ = c.Error(err)
+		_ = c.Error(dao.NotFoundAs(err, lib.ErrRoleNotFound))
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(found))
+	response.OK(c, found)
 }
 
 func roles(c *gin.Context) {
@@ -90,15 +115,15 @@ func roles(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	rows, count, err := query.Find()
+	rows, count, err := query.Find(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(map[string]interface{}{
+	response.OK(c, map[string]interface{}{
 		"count": count,
 		"rows":  rows,
-	}))
+	})
 }
 
 func grantRole(c *gin.Context) {
@@ -107,12 +132,12 @@ func grantRole(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Grant()
+	err := body.Grant(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func revokeRole(c *gin.Context) {
@@ -121,12 +146,12 @@ func revokeRole(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Revoke()
+	err := body.Revoke(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func changeRole(c *gin.Context) {
@@ -135,12 +160,12 @@ func changeRole(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Change()
+	err := body.Change(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func activeRole(c *gin.Context) {
@@ -149,12 +174,12 @@ func activeRole(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Active()
+	err := body.Active(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func deactiveRole(c *gin.Context) {
@@ -163,10 +188,10 @@ func deactiveRole(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Deactive()
+	err := body.Deactive(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
@@ -1,10 +1,9 @@
 package v1
 
 import (
-	"app/lib"
+	"app/lib/response"
 	"app/repository/dao"
 	"app/repository/dto"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,12 +16,12 @@ func follow(c *gin.Context) {
 	}
 	auth := c.GetStringMap("auth")
 	id := auth["id"].(string)
-	me, err := body.Follow(id)
+	me, err := body.Follow(c.Request.Context(), id)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(me))
+	response.OK(c, me)
 }
 
 func unfollow(c *gin.Context) {
@@ -33,34 +32,30 @@ func unfollow(c *gin.Context) {
 	}
 	auth := c.GetStringMap("auth")
 	id := auth["id"].(string)
-	me, err := body.Unfollow(id)
+	me, err := body.Unfollow(c.Request.Context(), id)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(me))
+	response.OK(c, me)
 }
 
 func fans(c *gin.Context) {
 	id := c.Param("id")
-	user, err := dao.FindUser(id, map[string]interface{}{
-		"preload": []string{"Fans"},
-	})
+	user, err := dao.Users.Find(c.Request.Context(), id, dao.NewQuery().Preload("Fans"))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(user))
+	response.OK(c, user)
 }
 
 func followings(c *gin.Context) {
 	id := c.Param("id")
-	user, err := dao.FindUser(id, map[string]interface{}{
-		"preload": []string{"Followings"},
-	})
+	user, err := dao.Users.Find(c.Request.Context(), id, dao.NewQuery().Preload("Followings"))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(user))
+	response.OK(c, user)
 }
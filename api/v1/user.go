@@ -2,9 +2,9 @@ package v1
 
 import (
 	"app/lib"
+	"app/lib/response"
 	"app/repository/dao"
 	"app/repository/dto"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,27 +15,25 @@ func users(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	rows, count, err := query.Find()
+	rows, count, err := query.Find(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(map[string]interface{}{
+	response.OK(c, map[string]interface{}{
 		"count": count,
 		"rows":  rows,
-	}))
+	})
 }
 
 func user(c *gin.Context) {
 	id := c.Param("id")
-	user, err := dao.FindUser(id, map[string]interface{}{
-		"preload": []string{"Group", "Role", "Role.Actions"},
-	})
+	user, err := dao.Users.Find(c.Request.Context(), id, dao.NewQuery().Preload("Group").Preload("Role").Preload("Role.Actions"))
 	if err != nil {
-		_ = c.Error(err)
+		_ = c.Error(dao.NotFoundAs(err, lib.ErrUserNotFound))
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(user))
+	response.OK(c, user)
 }
 
 func updateUser(c *gin.Context) {
@@ -45,22 +43,37 @@ func updateUser(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	updated, err := body.Save(id)
+	updated, err := body.Save(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, updated)
+}
+
+func patchUser(c *gin.Context) {
+	p, err := bindPatch(c)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(updated))
+	var body dto.PatchUser
+	patched, err := body.Patch(c.Request.Context(), c.Param("id"), p)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response.OK(c, patched)
 }
 
 func deleteUser(c *gin.Context) {
 	id := c.Param("id")
-	deleted, err := dao.DeleteUser(id)
+	_, err := dao.Users.Delete(c.Request.Context(), id)
 	if err != nil {
-		_ = c.Error(err)
+		_ = c.Error(dao.NotFoundAs(err, lib.ErrUserNotFound))
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(deleted))
+	response.NoContent(c)
 }
 
 func activeUser(c *gin.Context) {
@@ -69,12 +82,12 @@ func activeUser(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Active()
+	err := body.Active(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
 
 func deactiveUser(c *gin.Context) {
@@ -83,10 +96,10 @@ func deactiveUser(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	err := body.Deactive()
+	err := body.Deactive(c.Request.Context())
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, lib.Reply(nil))
+	response.NoContent(c)
 }
@@ -1,33 +1,123 @@
 package v1
 
 import (
+	"app/lib/patch"
+	"app/lib/response"
 	"app/middleware"
-	"net/http"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// permissions maps "METHOD route" to the action values required to call it
+var permissions = map[string]string{
+	"POST reset/:id/password": "USER_WRITE",
+	"PUT user/:id":            "USER_WRITE",
+	"PATCH user/:id":          "USER_WRITE",
+	"DELETE user/:id":         "USER_WRITE",
+	"POST active/user":        "USER_WRITE",
+	"POST deactive/user":      "USER_WRITE",
+
+	"POST role":          "ROLE_WRITE",
+	"PUT role/:id":       "ROLE_WRITE",
+	"PATCH role/:id":     "ROLE_WRITE",
+	"DELETE role/:id":    "ROLE_WRITE",
+	"POST user/role":     "ROLE_WRITE",
+	"DELETE user/role":   "ROLE_WRITE",
+	"PUT user/role":      "ROLE_WRITE",
+	"POST active/role":   "ROLE_WRITE",
+	"DELETE active/role": "ROLE_WRITE",
+
+	"POST action-category":       "ACTION_WRITE",
+	"PUT action-category/:id":    "ACTION_WRITE",
+	"PATCH action-category/:id":  "ACTION_WRITE",
+	"DELETE action-category/:id": "ACTION_WRITE",
+	"POST action":                "ACTION_WRITE",
+	"PUT action/:id":             "ACTION_WRITE",
+	"PATCH action/:id":           "ACTION_WRITE",
+	"DELETE action/:id":          "ACTION_WRITE",
+	"POST role/action":           "ACTION_WRITE",
+	"DELETE role/action":         "ACTION_WRITE",
+	"PUT role/action":            "ACTION_WRITE",
+}
+
+// own lists the routes of permissions a user calls on the :id of their own
+// account without the action
+var own = map[string]bool{
+	"PUT user/:id":   true,
+	"PATCH user/:id": true,
+}
+
+// unless lists the routes called without an access token, patterns match the
+// path below the base path
+var unless = map[string]string{
+	"ping$":   "get",
+	"public/": "post|get",
+}
+
+// basePath is where ApplyRoutes mounts the routes, Location headers point below it
+var basePath string
+
+// location is the path of the resource id served by route
+func location(route string, id interface{}) string {
+	return path.Join(basePath, route, fmt.Sprint(id))
+}
+
+// bindPatch reads the body of a PATCH request, its Content-Type picks a JSON
+// Merge Patch or a JSON Patch
+func bindPatch(c *gin.Context) (patch.Patch, error) {
+	data, err := c.GetRawData()
+	return patch.Patch{MediaType: c.ContentType(), Data: data}, err
+}
+
+// permissionRules keys routes of "METHOD route" by "METHOD /full/path" below basePath
+func permissionRules[V any](basePath string, routes map[string]V) map[string]V {
+	rules := make(map[string]V)
+	for route, value := range routes {
+		sp := strings.SplitN(route, " ", 2)
+		rules[sp[0]+" "+path.Join(basePath, sp[1])] = value
+	}
+	return rules
+}
+
+// unlessRules anchors the patterns of unless to basePath
+func unlessRules(basePath string) map[string]string {
+	rules := make(map[string]string)
+	for pattern, methods := range unless {
+		rules["^"+regexp.QuoteMeta(basePath+"/")+pattern] = methods
+	}
+	return rules
+}
+
 func ApplyRoutes(r *gin.RouterGroup) {
+	authorizeChannels()
 	v1 := r.Group("v1")
+	basePath = v1.BasePath()
 	{
-		v1.Use(middleware.JWT(map[string]string{
-			"ping":   "get",
-			"public": "post|get",
-		}))
+		v1.Use(middleware.JWT(unlessRules(v1.BasePath())))
+		v1.Use(middleware.Permission(permissionRules(v1.BasePath(), permissions), permissionRules(v1.BasePath(), own)))
 		v1.GET("ping", func(c *gin.Context) {
-			c.JSON(http.StatusOK, &gin.H{
-				"code": 0, "message": "pong",
-			})
+			response.OK(c, pong{Message: "pong"})
 		})
 		v1.POST("public/register", register)
 		v1.POST("public/login", login)
+		v1.POST("public/refresh", refresh)
+		v1.POST("logout", logout)
 		v1.POST("change/password", changePassword)
+		v1.POST("change/locale", changeLocale)
 		v1.POST("reset/:id/password", resetPassword)
 		v1.GET("public/message", messager)
+		v1.GET("message/unread", unreadMessages)
+		v1.GET("message/user/:userID", conversation)
+		v1.POST("message/read", readMessages)
 
 		v1.GET("public/user", users)
 		v1.GET("public/user/:id", user)
 		v1.PUT("user/:id", updateUser)
+		v1.PATCH("user/:id", patchUser)
 		v1.DELETE("user/:id", deleteUser)
 		v1.POST("active/user", activeUser)
 		v1.POST("deactive/user", deactiveUser)
@@ -38,9 +128,19 @@ func ApplyRoutes(r *gin.RouterGroup) {
 		v1.GET("user/:id/fans", fans)
 		v1.GET("user/:id/following", followings)
 
+		v1.POST("group", createGroup)
+		v1.GET("public/group", groups)
+		v1.GET("public/group/:id", group)
+		v1.PUT("group/:id", updateGroup)
+		v1.PATCH("group/:id", patchGroup)
+		v1.DELETE("group", deleteGroups)
+		v1.POST("group/user", joinGroup)
+		v1.DELETE("group/user", leaveGroup)
+
 		v1.POST("role", createRole)
 		v1.GET("public/role", roles)
 		v1.PUT("role/:id", updateRole)
+		v1.PATCH("role/:id", patchRole)
 		v1.DELETE("role/:id", deleteRole)
 		v1.GET("public/role/:id", role)
 		v1.POST("user/role", grantRole)
@@ -51,6 +151,7 @@ func ApplyRoutes(r *gin.RouterGroup) {
 
 		v1.POST("action-category", createActionCategory)
 		v1.PUT("action-category/:id", updateActionCategory)
+		v1.PATCH("action-category/:id", patchActionCategory)
 		v1.GET("public/action-category/:id", actionCategory)
 		v1.GET("public/action-category", actionCategories)
 		v1.DELETE("action-category/:id", deleteActionCategory)
@@ -58,6 +159,7 @@ func ApplyRoutes(r *gin.RouterGroup) {
 		v1.POST("action", createAction)
 		v1.GET("public/action", actions)
 		v1.PUT("action/:id", updateAction)
+		v1.PATCH("action/:id", patchAction)
 		v1.DELETE("action/:id", deleteAction)
 		v1.GET("public/action/:id", action)
 		v1.POST("role/action", grantAction)
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"app/lib"
+	"testing"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	username := unique("register")
+	var registered struct {
+		Token string `json:"token"`
+		User  struct {
+			ID     string `json:"id"`
+			RoleID uint   `json:"roleID"`
+		} `json:"user"`
+	}
+	ok(t, "POST", "public/register", "", map[string]string{
+		"username": username, "password": "secret", "repeatPassword": "secret", "email": username + "@example.com",
+	}, &registered)
+	if registered.Token == "" {
+		t.Fatal("register replied no token")
+	}
+	if registered.User.RoleID != memberRoleID {
+		t.Fatalf("registered with role %d want the default role %d", registered.User.RoleID, memberRoleID)
+	}
+
+	var me struct {
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+	}
+	ok(t, "GET", "me", registered.Token, nil, &me)
+	if me.User.Username != username {
+		t.Fatalf("me is %s want %s", me.User.Username, username)
+	}
+
+	fails(t, lib.ErrUserExists, "POST", "public/register", "", map[string]string{
+		"username": username, "password": "secret", "repeatPassword": "secret", "email": username + "@example.com",
+	})
+	reply := fails(t, lib.ErrValidation, "POST", "public/register", "", map[string]string{
+		"username": unique("register"), "password": "secret", "repeatPassword": "other", "email": "a@example.com",
+	})
+	if details, _ := reply.Details.(map[string]interface{}); details["repeatPassword"] == nil {
+		t.Fatalf("validation details %v name no repeatPassword", reply.Details)
+	}
+
+	var tokens struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	ok(t, "POST", "public/login", "", map[string]string{
+		"username": username, "password": "secret",
+	}, &tokens)
+	if tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Fatal("login replied no tokens")
+	}
+	fails(t, lib.ErrPasswordWrong, "POST", "public/login", "", map[string]string{
+		"username": username, "password": "wrong",
+	})
+	fails(t, lib.ErrUserNotFound, "POST", "public/login", "", map[string]string{
+		"username": unique("nobody"), "password": "secret",
+	})
+}
+
+func TestLoginOfDeactivatedUser(t *testing.T) {
+	admin := newUser(t, adminRoleID)
+	user := newUser(t, memberRoleID)
+	ok(t, "POST", "deactive/user", login(t, admin), map[string]string{"userID": user.ID}, nil)
+	fails(t, lib.ErrUserInactive, "POST", "public/login", "", map[string]string{
+		"username": user.Username, "password": fixturePassword,
+	})
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	token := login(t, newUser(t, memberRoleID))
+	ok(t, "GET", "me", token, nil, nil)
+	ok(t, "POST", "logout", token, map[string]string{}, nil)
+	fails(t, lib.ErrTokenRevoked, "GET", "me", token, nil)
+}
+
+func TestPasswordChangeInvalidatesTokens(t *testing.T) {
+	user := newUser(t, memberRoleID)
+	token := login(t, user)
+	ok(t, "POST", "change/password", token, map[string]string{
+		"oldPassword": fixturePassword, "newPassword": "changed", "repeatPassword": "changed",
+	}, nil)
+	fails(t, lib.ErrTokenInvalid, "GET", "me", token, nil)
+	// a token signed in the same second as the change is valid
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	ok(t, "POST", "public/login", "", map[string]string{"username": user.Username, "password": "changed"}, &tokens)
+	ok(t, "GET", "me", tokens.Token, nil, nil)
+}
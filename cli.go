@@ -0,0 +1,253 @@
+package main
+
+import (
+	"app/lib/config"
+	"app/repository/dao"
+	"app/repository/dto"
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+// commands are looked up by their first one or two words, serve runs without any
+var commands = map[string]command{
+	"serve":               {"serve", serve},
+	"migrate up":          {"migrate up [-steps N]", migrateUp},
+	"migrate down":        {"migrate down [-steps N]", migrateDown},
+	"migrate status":      {"migrate status", migrateStatus},
+	"seed":                {"seed", seed},
+	"user create-admin":   {"user create-admin [-username NAME] [-email EMAIL] [-role ID] [-password-stdin]", createAdmin},
+	"user reset-password": {"user reset-password -username NAME [-password-stdin]", resetPassword},
+	"role list":           {"role list", listRoles},
+}
+
+var commandOrder = []string{"serve", "migrate up", "migrate down", "migrate status", "seed", "user create-admin", "user reset-password", "role list"}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [-version] [-config FILE] [-profile NAME] <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, name := range commandOrder {
+		fmt.Fprintf(out, "  %s\n", commands[name].usage)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return serve(args)
+	}
+	if len(args) > 1 {
+		if cmd, ok := commands[args[0]+" "+args[1]]; ok {
+			return cmd.run(args[2:])
+		}
+	}
+	if cmd, ok := commands[args[0]]; ok {
+		return cmd.run(args[1:])
+	}
+	usage()
+	return fmt.Errorf("unknown command %q", strings.Join(args, " "))
+}
+
+// connect reads config and opens the database for commands other than serve,
+// queries of the command are cancelled by ctrl+c
+func connect() (context.Context, func(), error) {
+	if err := config.Load(configFile, profile); err != nil {
+		return nil, nil, err
+	}
+	dao.Init(config.App.Driver, config.App.Dsn)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	return ctx, func() {
+		stop()
+		dao.Close()
+	}, nil
+}
+
+func migrateUp(args []string) error {
+	return migrate("up", dao.MigrateUp, args)
+}
+
+func migrateDown(args []string) error {
+	return migrate("down", dao.MigrateDown, args)
+}
+
+func migrate(direction string, apply func(ctx context.Context, steps int) ([]dao.Migration, error), args []string) error {
+	fs := flag.NewFlagSet("migrate "+direction, flag.ExitOnError)
+	steps := fs.Int("steps", 0, "number of migrations to apply or roll back, up defaults to all and down to 1")
+	fs.Parse(args)
+	ctx, disconnect, err := connect()
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+	done, err := apply(ctx, *steps)
+	for _, m := range done {
+		fmt.Printf("%s %04d_%s\n", direction, m.Version, m.Name)
+	}
+	if err == nil && len(done) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+	return err
+}
+
+func migrateStatus(args []string) error {
+	fs := flag.NewFlagSet("migrate status", flag.ExitOnError)
+	fs.Parse(args)
+	ctx, disconnect, err := connect()
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+	statuses, err := dao.MigrationStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, s := range statuses {
+		state := "pending"
+		if s.AppliedAt != nil {
+			state = "applied at " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d_%s\t%s\n", s.Version, s.Name, state)
+	}
+	return w.Flush()
+}
+
+func seed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	fs.Parse(args)
+	ctx, disconnect, err := connect()
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+	if err := dao.Seed(ctx); err != nil {
+		return err
+	}
+	fmt.Println("seeded default actions and roles")
+	return nil
+}
+
+// readPassword takes the first line of stdin when fromStdin is set, otherwise
+// generates a random password that is printed once
+func readPassword(fromStdin bool) (string, bool, error) {
+	if fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if err != nil {
+				return "", false, err
+			}
+			return "", false, errors.New("empty password")
+		}
+		return line, false, nil
+	}
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), true, nil
+}
+
+func createAdmin(args []string) error {
+	fs := flag.NewFlagSet("user create-admin", flag.ExitOnError)
+	username := fs.String("username", "admin", "username of the admin")
+	email := fs.String("email", "", "email of the admin")
+	roleID := fs.Uint("role", 0, "role id, defaults to the seeded admin role")
+	fromStdin := fs.Bool("password-stdin", false, "read the password from stdin instead of generating one")
+	fs.Parse(args)
+	password, generated, err := readPassword(*fromStdin)
+	if err != nil {
+		return err
+	}
+	ctx, disconnect, err := connect()
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+	created, err := dao.CreateAdmin(ctx, dao.User{
+		Username: *username,
+		Password: password,
+		Email:    *email,
+	}, *roleID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created user %s (%s)\n", created.Username, created.ID)
+	if generated {
+		fmt.Printf("password: %s\n", password)
+	}
+	return nil
+}
+
+func resetPassword(args []string) error {
+	fs := flag.NewFlagSet("user reset-password", flag.ExitOnError)
+	username := fs.String("username", "", "username of the user")
+	fromStdin := fs.Bool("password-stdin", false, "read the password from stdin instead of generating one")
+	fs.Parse(args)
+	if *username == "" {
+		return errors.New("username is required")
+	}
+	password, generated, err := readPassword(*fromStdin)
+	if err != nil {
+		return err
+	}
+	ctx, disconnect, err := connect()
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+	exists, user, err := dao.Users.ExistsBy(ctx, "username", *username)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("user %s not found", *username)
+	}
+	body := dto.ResetPassword{NewPassword: password, RepeatPassword: password}
+	if _, err := body.ResetPassword(ctx, user.ID); err != nil {
+		return err
+	}
+	fmt.Printf("reset password of %s, signed in sessions are revoked\n", user.Username)
+	if generated {
+		fmt.Printf("password: %s\n", password)
+	}
+	return nil
+}
+
+func listRoles(args []string) error {
+	fs := flag.NewFlagSet("role list", flag.ExitOnError)
+	fs.Parse(args)
+	ctx, disconnect, err := connect()
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+	roles, err := dao.Roles.FindAll(ctx, dao.NewQuery().Preload("Actions").Order("id"))
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tDEFAULT\tACTIVE\tACTIONS")
+	for _, role := range roles {
+		values := make([]string, 0, len(role.Actions))
+		for _, action := range role.Actions {
+			values = append(values, action.Value)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%t\t%t\t%s\n", role.ID, role.Name, role.IsDefault, role.IsActived, strings.Join(values, ","))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"app/lib"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	fails(t, lib.ErrUserNotFound, "GET", "public/user/missing", "", nil)
+	fails(t, lib.ErrAuthHeaderMissing, "GET", "me", "", nil)
+	fails(t, lib.ErrTokenInvalid, "GET", "me", "not-a-token", nil)
+	// the query string never makes a route public
+	fails(t, lib.ErrAuthHeaderMissing, "GET", "me?public", "", nil)
+	fails(t, lib.ErrAuthHeaderMissing, "POST", "logout?x=/ping", "", map[string]string{})
+
+	adminToken := login(t, newUser(t, adminRoleID))
+	taken := newRole(t)
+	role := newRole(t)
+	reply := fails(t, lib.ErrConflict, "PUT", fmt.Sprintf("role/%d", role.ID), adminToken, map[string]string{"name": taken.Name})
+	if reply.Msg != lib.ErrConflict.Message(lib.GetTranslator("zh")) || reply.Details != nil {
+		t.Fatalf("conflict replied %s %v, the database error must not leak", reply.Msg, reply.Details)
+	}
+	fails(t, lib.ErrBadRequest, "PUT", "role/abc", adminToken, map[string]string{"name": unique("role")})
+}
+
+func TestMalformedBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v1/public/login", strings.NewReader("{"))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), lib.ErrBadRequest.Reason) {
+		t.Fatalf("malformed body replied %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDocumentedNotFound(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	role := newRole(t)
+	missing := unique("missing")
+
+	fails(t, lib.ErrUserNotFound, "GET", "public/user/"+missing, "", nil)
+	fails(t, lib.ErrRoleNotFound, "GET", "public/role/999999", "", nil)
+	fails(t, lib.ErrGroupNotFound, "GET", "public/group/"+missing, "", nil)
+	fails(t, lib.ErrUserNotFound, "DELETE", "user/"+missing, adminToken, nil)
+
+	user := newUser(t, memberRoleID)
+	reply := fails(t, lib.ErrUserNotFound, "POST", "deactive/user", adminToken, map[string]string{"userID": user.ID + "," + missing})
+	if fmt.Sprint(reply.Details) != "["+missing+"]" {
+		t.Errorf("details are %v", reply.Details)
+	}
+	if !findUser(t, user.ID).IsActived {
+		t.Error("a failed deactivation changed the known user")
+	}
+	fails(t, lib.ErrRoleNotFound, "POST", "active/role", adminToken, map[string]string{"roleID": "999999"})
+	fails(t, lib.ErrRoleNotFound, "POST", "user/role", adminToken, map[string]interface{}{"userID": user.ID, "roleID": 999999})
+	fails(t, lib.ErrUserNotFound, "POST", "user/role", adminToken, map[string]interface{}{"userID": missing, "roleID": role.ID})
+	fails(t, lib.ErrActionNotFound, "POST", "role/action", adminToken, map[string]interface{}{"actionID": missing, "roleID": role.ID})
+}
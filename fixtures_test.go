@@ -0,0 +1,129 @@
+package main
+
+import (
+	"app/repository/dao"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	adminRoleID      uint = 1
+	groupAdminRoleID uint = 2
+	memberRoleID     uint = 3
+	fixturePassword       = "password"
+)
+
+var fixtureSeq int64
+
+// unique names fixtures so tests sharing the database never collide
+func unique(prefix string) string {
+	return fmt.Sprintf("%s%d", prefix, atomic.AddInt64(&fixtureSeq, 1))
+}
+
+// newUser creates an active user with the role of roleID and fixturePassword
+func newUser(t *testing.T, roleID uint) dao.User {
+	t.Helper()
+	user, err := dao.CreateAdmin(context.Background(), dao.User{
+		Username: unique("user"),
+		Password: fixturePassword,
+	}, roleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// login signs the user in and returns the access token
+func login(t *testing.T, user dao.User) string {
+	t.Helper()
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	ok(t, "POST", "public/login", "", map[string]string{
+		"username": user.Username, "password": fixturePassword,
+	}, &tokens)
+	return tokens.Token
+}
+
+// newAction creates an active action in the seeded category
+func newAction(t *testing.T, value string) dao.Action {
+	t.Helper()
+	ctx := context.Background()
+	exists, category, err := dao.ActionCategories.ExistsBy(ctx, "name", "基础权限")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("seeded action category not found")
+	}
+	action, err := dao.Action{
+		Name: unique("action"), Value: value, IsActived: true, CategoryID: category.ID,
+	}.Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return action
+}
+
+// seededAction finds an action created by dao.Seed by its value
+func seededAction(t *testing.T, value string) dao.Action {
+	t.Helper()
+	exists, action, err := dao.Actions.ExistsBy(context.Background(), "value", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatalf("seeded action %s not found", value)
+	}
+	return action
+}
+
+// newRole creates an active role granted the given actions
+func newRole(t *testing.T, actions ...dao.Action) dao.Role {
+	t.Helper()
+	role, err := dao.Role{
+		Name: unique("role"), IsActived: true,
+	}.Create(context.Background(), actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return role
+}
+
+// newGroup creates a group owned by owner, who becomes its group admin
+func newGroup(t *testing.T, owner *dao.User) dao.Group {
+	t.Helper()
+	ctx := context.Background()
+	role, err := dao.Roles.Find(ctx, groupAdminRoleID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := dao.Group{Name: unique("group")}
+	created, err := group.Create(ctx, owner, &role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner.GroupID = &created.ID
+	owner.RoleID = &role.ID
+	return created
+}
+
+func findUser(t *testing.T, id string) dao.User {
+	t.Helper()
+	user, err := dao.Users.Find(context.Background(), id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func findGroup(t *testing.T, id string) dao.Group {
+	t.Helper()
+	group, err := dao.Groups.Find(context.Background(), id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return group
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"app/lib"
+	"testing"
+)
+
+func TestCreateGroup(t *testing.T) {
+	owner := newUser(t, memberRoleID)
+	token := login(t, owner)
+	name := unique("group")
+
+	var created struct {
+		ID      string `json:"id"`
+		OwnerID string `json:"ownerID"`
+	}
+	ok(t, "POST", "group", token, map[string]string{"name": name}, &created)
+	if created.OwnerID != owner.ID {
+		t.Fatalf("group owner is %s want %s", created.OwnerID, owner.ID)
+	}
+	found := findUser(t, owner.ID)
+	if found.GroupID == nil || *found.GroupID != created.ID {
+		t.Fatalf("owner joined group %v want %s", found.GroupID, created.ID)
+	}
+	if found.RoleID == nil || *found.RoleID != groupAdminRoleID {
+		t.Fatalf("owner has role %v want the group admin role %d", found.RoleID, groupAdminRoleID)
+	}
+
+	fails(t, lib.ErrAlreadyInGroup, "POST", "group", token, map[string]string{"name": unique("group")})
+	fails(t, lib.ErrGroupExists, "POST", "group", login(t, newUser(t, memberRoleID)), map[string]string{"name": name})
+}
+
+func TestGroupMembership(t *testing.T) {
+	owner, member, outsider := newUser(t, memberRoleID), newUser(t, memberRoleID), newUser(t, memberRoleID)
+	group := newGroup(t, &owner)
+	ownerToken := login(t, owner)
+	io := map[string]string{"groupID": group.ID, "userID": member.ID}
+
+	fails(t, lib.ErrGroupDenied, "POST", "group/user", login(t, outsider), io)
+
+	ok(t, "POST", "group/user", ownerToken, io, nil)
+	if amount := findGroup(t, group.ID).Amount; amount != group.Amount+1 {
+		t.Fatalf("group has %d members want %d", amount, group.Amount+1)
+	}
+	if groupID := findUser(t, member.ID).GroupID; groupID == nil || *groupID != group.ID {
+		t.Fatalf("member joined group %v want %s", groupID, group.ID)
+	}
+
+	// members of a group are not added again
+	ok(t, "POST", "group/user", ownerToken, io, nil)
+	if amount := findGroup(t, group.ID).Amount; amount != group.Amount+1 {
+		t.Fatalf("adding twice counted %d members want %d", amount, group.Amount+1)
+	}
+
+	// members leave by themselves
+	ok(t, "DELETE", "group/user", login(t, member), io, nil)
+	if amount := findGroup(t, group.ID).Amount; amount != group.Amount {
+		t.Fatalf("group has %d members after leaving want %d", amount, group.Amount)
+	}
+	left := findUser(t, member.ID)
+	if left.GroupID != nil {
+		t.Fatalf("member still in group %s", *left.GroupID)
+	}
+	if left.RoleID == nil || *left.RoleID != memberRoleID {
+		t.Fatalf("member left with role %v want the default role %d", left.RoleID, memberRoleID)
+	}
+
+	fails(t, lib.ErrGroupOwnerLeave, "DELETE", "group/user", ownerToken, map[string]string{"groupID": group.ID, "userID": owner.ID})
+	fails(t, lib.ErrGroupNotFound, "POST", "group/user", ownerToken, map[string]string{"groupID": "missing", "userID": member.ID})
+}
+
+func TestGroupManagerAddsMembers(t *testing.T) {
+	owner, member := newUser(t, memberRoleID), newUser(t, memberRoleID)
+	group := newGroup(t, &owner)
+	io := map[string]string{"groupID": group.ID, "userID": member.ID}
+
+	ok(t, "POST", "group/user", login(t, newUser(t, adminRoleID)), io, nil)
+	if groupID := findUser(t, member.ID).GroupID; groupID == nil || *groupID != group.ID {
+		t.Fatalf("member joined group %v want %s", groupID, group.ID)
+	}
+	ok(t, "DELETE", "group/user", login(t, owner), io, nil)
+	if groupID := findUser(t, member.ID).GroupID; groupID != nil {
+		t.Fatalf("removed member still in group %s", *groupID)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"app/lib"
+	"strings"
+	"testing"
+)
+
+func TestAcceptLanguage(t *testing.T) {
+	zh, en := lib.GetTranslator("zh"), lib.GetTranslator("en")
+	cases := []struct {
+		acceptLanguage string
+		msg            string
+	}{
+		{"", lib.ErrUserNotFound.Message(zh)},
+		{"en-US,en;q=0.9", lib.ErrUserNotFound.Message(en)},
+		{"fr-FR, en;q=0.8, zh;q=0.5", lib.ErrUserNotFound.Message(en)},
+		{"zh-CN", lib.ErrUserNotFound.Message(zh)},
+		// unsupported languages fall back to the config locale
+		{"fr-FR", lib.ErrUserNotFound.Message(zh)},
+	}
+	for _, tc := range cases {
+		reply := callWithHeader(t, "POST", "public/login", "", map[string]string{
+			"username": "missing", "password": "secret",
+		}, map[string]string{"Accept-Language": tc.acceptLanguage})
+		if reply.Reason != lib.ErrUserNotFound.Reason || reply.Msg != tc.msg {
+			t.Fatalf("Accept-Language %q replied %s %q want %q", tc.acceptLanguage, reply.Reason, reply.Msg, tc.msg)
+		}
+	}
+	if lib.ErrUserNotFound.Message(zh) == lib.ErrUserNotFound.Message(en) {
+		t.Fatal("zh and en catalogues reply the same message")
+	}
+}
+
+func TestValidationDetailsLocale(t *testing.T) {
+	reply := callWithHeader(t, "POST", "public/register", "", map[string]string{
+		"username": unique("register"), "password": "secret", "repeatPassword": "other", "email": "a@example.com",
+	}, map[string]string{"Accept-Language": "en"})
+	details, _ := reply.Details.(map[string]interface{})
+	msg, _ := details["repeatPassword"].(string)
+	if reply.Msg != lib.ErrValidation.Message(lib.GetTranslator("en")) || !strings.Contains(msg, "must be equal to") {
+		t.Fatalf("validation replied %q %v in english", reply.Msg, reply.Details)
+	}
+	if got := reply.header.Get("Content-Language"); got != "en" {
+		t.Fatalf("Content-Language is %q want en", got)
+	}
+}
+
+func TestPreferredLocale(t *testing.T) {
+	user := newUser(t, memberRoleID)
+	token := login(t, user)
+	ok(t, "POST", "change/locale", token, map[string]string{"locale": "en"}, nil)
+	fails(t, lib.ErrValidation, "POST", "change/locale", token, map[string]string{"locale": "fr"})
+
+	// the preference is carried by tokens signed after the change and overrides Accept-Language
+	token = login(t, user)
+	reply := callWithHeader(t, "GET", "public/user/missing", "", nil, map[string]string{"Accept-Language": "en"})
+	if reply.Msg != lib.ErrUserNotFound.Message(lib.GetTranslator("en")) {
+		t.Fatalf("public route replied %q", reply.Msg)
+	}
+	reply = callWithHeader(t, "POST", "follow/user", token, map[string]string{"userID": "missing"},
+		map[string]string{"Accept-Language": "zh-CN"})
+	if reply.Msg != lib.ErrNotFound.Message(lib.GetTranslator("en")) {
+		t.Fatalf("preferred locale en replied %q", reply.Msg)
+	}
+
+	ok(t, "POST", "change/locale", token, map[string]string{"locale": ""}, nil)
+	token = login(t, user)
+	reply = callWithHeader(t, "POST", "follow/user", token, map[string]string{"userID": "missing"},
+		map[string]string{"Accept-Language": "zh-CN"})
+	if reply.Msg != lib.ErrNotFound.Message(lib.GetTranslator("zh")) {
+		t.Fatalf("cleared preference replied %q", reply.Msg)
+	}
+}
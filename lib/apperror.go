@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"sort"
+	"strings"
+)
+
+// AppError is a failure replied to clients. Code and Reason are stable so
+// clients branch on them instead of the message, Status is the http status and
+// Key names the message in the catalogue of each locale.
+type AppError struct {
+	Code    int
+	Reason  string
+	Status  int
+	Key     string
+	Details interface{}
+	cause   error
+}
+
+var appErrors = make(map[string]*AppError)
+
+// newAppError adds an error to the catalogue, the message key is the lower case reason
+func newAppError(code int, status int, reason string) *AppError {
+	e := &AppError{
+		Code: code, Reason: reason, Status: status, Key: strings.ToLower(reason),
+	}
+	if _, ok := appErrors[reason]; ok {
+		panic("duplicated app error " + reason)
+	}
+	appErrors[reason] = e
+	return e
+}
+
+// AppErrors lists the catalogue of errors ordered by code
+func AppErrors() []*AppError {
+	rows := make([]*AppError, 0, len(appErrors))
+	for _, e := range appErrors {
+		rows = append(rows, e)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Code < rows[j].Code })
+	return rows
+}
+
+// Message is the message of the error translated by trans, the reason is
+// returned when the catalogue misses it
+func (e *AppError) Message(trans Translator) string {
+	msg, err := trans.T(e.Key)
+	if err != nil {
+		return e.Reason
+	}
+	return msg
+}
+
+// Error is the message in the default locale
+func (e *AppError) Error() string {
+	msg := e.Message(currentTranslator())
+	if e.cause != nil {
+		return msg + ": " + e.cause.Error()
+	}
+	return msg
+}
+
+func (e *AppError) Unwrap() error {
+	return e.cause
+}
+
+// Is matches errors of the same reason, copies made by WithDetails and Wrap included
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Reason == e.Reason
+}
+
+// WithDetails returns a copy of the error replying details
+func (e *AppError) WithDetails(details interface{}) *AppError {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// Wrap returns a copy of the error caused by err, the cause is logged but never replied
+func (e *AppError) Wrap(err error) *AppError {
+	copied := *e
+	copied.cause = err
+	return &copied
+}
@@ -1,32 +1,281 @@
 package config
 
 import (
-	"log"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/spf13/viper"
 )
 
 var App = new(AppConf)
 
+// envPrefix names environment overrides, APP_JWT_SECRET sets jwtSecret and
+// APP_WEBSOCKET_BROKER sets broker of websocket
+const envPrefix = "APP"
+
 type AppConf struct {
-	Port           string `yaml:"port"`
-	Locale         string `yaml:"locale"`
-	LogDir         string `yaml:"logDir"`
+	Port   string `yaml:"port"`
+	Locale string `yaml:"locale"`
+	LogDir string `yaml:"logDir"`
+	// LogLevel is debug, info, warn or error
+	LogLevel       string `yaml:"logLevel"`
 	JWTSecret      string `yaml:"jwtSecret"`
 	GroupAdminRole string `yaml:"groupAdminRole"`
 	DefaultRole    string `yaml:"defaultRole"`
-	Dsn            string `yaml:"dsn"`
+	// Driver is postgres, mysql or sqlite, dsn is written for it
+	Driver string `yaml:"driver"`
+	Dsn    string `yaml:"dsn"`
+	// QueryTimeout bounds the database queries of a request, like 10s
+	QueryTimeout time.Duration `yaml:"queryTimeout"`
+	// AutoMigrate applies pending migrations on start, otherwise run the `migrate up` subcommand
+	AutoMigrate bool `yaml:"autoMigrate"`
+	// AccessTokenTTL and RefreshTokenTTL accept durations like 15m or 720h
+	AccessTokenTTL  time.Duration `yaml:"accessTokenTTL"`
+	RefreshTokenTTL time.Duration `yaml:"refreshTokenTTL"`
+	// RevocationStore is memory or database
+	RevocationStore string `yaml:"revocationStore"`
+	// LegacyEnvelope replies everything with 200 and the code of the envelope for clients of earlier releases
+	LegacyEnvelope bool `yaml:"legacyEnvelope"`
+	// AllowedOrigins lists origins allowed to call the api and open websocket connections
+	AllowedOrigins []string      `yaml:"allowedOrigins"`
+	Websocket      WebsocketConf `yaml:"websocket"`
+}
+
+type WebsocketConf struct {
+	PingPeriod     time.Duration `yaml:"pingPeriod"`
+	PongWait       time.Duration `yaml:"pongWait"`
+	WriteWait      time.Duration `yaml:"writeWait"`
+	MaxMessageSize int64         `yaml:"maxMessageSize"`
+	SendQueueSize  int           `yaml:"sendQueueSize"`
+	// SlowConsumer is drop to discard messages of a full send queue or disconnect to close the connection
+	SlowConsumer string `yaml:"slowConsumer"`
+	// Broker is local for a single instance or postgres to fan out through LISTEN/NOTIFY on dsn
+	Broker string `yaml:"broker"`
+}
+
+// InvalidError lists every problem found while loading the config
+type InvalidError struct {
+	Problems []string
+}
+
+func (e *InvalidError) Error() string {
+	return "invalid config:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// ProfileFile is the file of profile next to file, config.prod.yml for config.yml
+func ProfileFile(file, profile string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + profile + ext
+}
+
+// Load reads the app section of file, merges the file of profile over it when
+// profile is set, applies APP_* environment overrides and validates the result.
+// App is only replaced when the config is valid, otherwise every problem is
+// reported at once by an *InvalidError.
+func Load(file, profile string) error {
+	conf, err := load(file, profile)
+	if err != nil {
+		return err
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	*App = *conf
+	source.file, source.profile = file, profile
+	return nil
+}
+
+func load(file, profile string) (*AppConf, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	if profile != "" {
+		v.SetConfigFile(ProfileFile(file, profile))
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", profile, err)
+		}
+	}
+	problems := applyEnv(v, "app", envPrefix, reflect.TypeOf(AppConf{}))
+	conf := new(AppConf)
+	if err := v.Unmarshal(&struct {
+		App *AppConf `mapstructure:"app"`
+	}{conf}); err != nil {
+		problems = append(problems, err.Error())
+	}
+	conf.setDefaults()
+	problems = append(problems, conf.validate()...)
+	if len(problems) > 0 {
+		return nil, &InvalidError{Problems: problems}
+	}
+	return conf, nil
+}
+
+// envName turns a yaml key into its environment name, jwtSecret into JWT_SECRET
+func envName(key string) string {
+	var b strings.Builder
+	runes := []rune(key)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(runes[i-1]) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
 }
 
-func Read() {
-	workDir, _ := os.Getwd()
-	viper.SetConfigFile(filepath.Join(workDir, "config.yml"))
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatal(err)
+// applyEnv overrides the keys of t with environment variables, NAME_FILE reads
+// the value of NAME from a file so secrets can be mounted instead of committed
+func applyEnv(v *viper.Viper, key, env string, t reflect.Type) []string {
+	var problems []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("yaml")
+		fieldKey := key + "." + strings.ToLower(name)
+		fieldEnv := env + "_" + envName(name)
+		if field.Type.Kind() == reflect.Struct {
+			problems = append(problems, applyEnv(v, fieldKey, fieldEnv, field.Type)...)
+			continue
+		}
+		value, ok := os.LookupEnv(fieldEnv)
+		if path, fromFile := os.LookupEnv(fieldEnv + "_FILE"); fromFile {
+			if ok {
+				problems = append(problems, fmt.Sprintf("%s and %s_FILE are both set", fieldEnv, fieldEnv))
+				continue
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s_FILE: %v", fieldEnv, err))
+				continue
+			}
+			value, ok = strings.TrimRight(string(content), "\r\n"), true
+		}
+		if ok {
+			v.Set(fieldKey, value)
+		}
+	}
+	return problems
+}
+
+func (c *AppConf) setDefaults() {
+	if c.Locale == "" {
+		c.Locale = "zh"
+	}
+	if c.LogDir == "" {
+		c.LogDir = "log"
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = "debug"
+	}
+	if c.Driver == "" {
+		c.Driver = "postgres"
+	}
+	if c.QueryTimeout == 0 {
+		c.QueryTimeout = 10 * time.Second
+	}
+	if c.AccessTokenTTL == 0 {
+		c.AccessTokenTTL = 15 * time.Minute
+	}
+	if c.RefreshTokenTTL == 0 {
+		c.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	if c.RevocationStore == "" {
+		c.RevocationStore = "memory"
+	}
+	ws := &c.Websocket
+	if ws.PongWait == 0 {
+		ws.PongWait = 60 * time.Second
+	}
+	if ws.PingPeriod == 0 || ws.PingPeriod >= ws.PongWait {
+		ws.PingPeriod = ws.PongWait * 9 / 10
+	}
+	if ws.WriteWait == 0 {
+		ws.WriteWait = 10 * time.Second
+	}
+	if ws.MaxMessageSize == 0 {
+		ws.MaxMessageSize = 64 * 1024
+	}
+	if ws.SendQueueSize == 0 {
+		ws.SendQueueSize = 256
+	}
+	if ws.SlowConsumer == "" {
+		ws.SlowConsumer = "disconnect"
+	}
+	if ws.Broker == "" {
+		ws.Broker = "local"
+	}
+}
+
+func (c *AppConf) validate() []string {
+	var problems []string
+	invalid := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		invalid("port %q is not a tcp port", c.Port)
+	}
+	if c.Locale != "zh" && c.Locale != "en" {
+		invalid("locale %q is not zh or en", c.Locale)
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("logLevel %q is not debug, info, warn or error", c.LogLevel)
+	}
+	if len(c.JWTSecret) < 32 {
+		invalid("jwtSecret needs at least 32 characters, set %s_JWT_SECRET or %s_JWT_SECRET_FILE", envPrefix, envPrefix)
+	}
+	if id, err := strconv.Atoi(c.GroupAdminRole); err != nil || id <= 0 {
+		invalid("groupAdminRole %q is not a role id", c.GroupAdminRole)
+	}
+	if id, err := strconv.Atoi(c.DefaultRole); err != nil || id <= 0 {
+		invalid("defaultRole %q is not a role id", c.DefaultRole)
+	}
+	switch c.Driver {
+	case "postgres", "sqlite":
+	case "mysql":
+		if !strings.Contains(strings.ToLower(c.Dsn), "parsetime=true") {
+			invalid("dsn of mysql needs parseTime=True")
+		}
+	default:
+		invalid("driver %q is not postgres, mysql or sqlite", c.Driver)
+	}
+	if c.Dsn == "" {
+		invalid("dsn is required, set %s_DSN or %s_DSN_FILE", envPrefix, envPrefix)
+	}
+	if c.QueryTimeout < 0 {
+		invalid("queryTimeout %s is negative", c.QueryTimeout)
+	}
+	if c.AccessTokenTTL < 0 || c.AccessTokenTTL >= c.RefreshTokenTTL {
+		invalid("accessTokenTTL %s must be shorter than refreshTokenTTL %s", c.AccessTokenTTL, c.RefreshTokenTTL)
+	}
+	if c.RevocationStore != "memory" && c.RevocationStore != "database" {
+		invalid("revocationStore %q is not memory or database", c.RevocationStore)
+	}
+	ws := c.Websocket
+	if ws.PongWait < 0 || ws.WriteWait < 0 {
+		invalid("websocket waits must not be negative")
+	}
+	if ws.MaxMessageSize < 0 || ws.SendQueueSize < 0 {
+		invalid("websocket maxMessageSize and sendQueueSize must not be negative")
+	}
+	if ws.SlowConsumer != "drop" && ws.SlowConsumer != "disconnect" {
+		invalid("websocket slowConsumer %q is not drop or disconnect", ws.SlowConsumer)
 	}
-	if err := viper.Sub("app").Unmarshal(App); err != nil {
-		log.Fatal(err)
+	switch ws.Broker {
+	case "local":
+	case "postgres":
+		if c.Driver != "postgres" {
+			invalid("websocket broker postgres needs the postgres driver")
+		}
+	default:
+		invalid("websocket broker %q is not local or postgres", ws.Broker)
 	}
+	return problems
 }
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const base = `app:
+  port: 2025
+  groupAdminRole: 2
+  defaultRole: 3
+  driver: sqlite
+  dsn: base.db
+  queryTimeout: 10s
+  allowedOrigins:
+    - http://localhost:3000
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestEnvName(t *testing.T) {
+	for key, want := range map[string]string{
+		"dsn":            "DSN",
+		"jwtSecret":      "JWT_SECRET",
+		"accessTokenTTL": "ACCESS_TOKEN_TTL",
+		"maxMessageSize": "MAX_MESSAGE_SIZE",
+	} {
+		if got := envName(key); got != want {
+			t.Errorf("envName(%s) = %s want %s", key, got, want)
+		}
+	}
+}
+
+func TestLoadProfileAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "config.yml", base)
+	writeFile(t, dir, "config.prod.yml", "app:\n  driver: postgres\n  revocationStore: database\n")
+	secret := writeFile(t, dir, "jwt_secret", "0123456789abcdef0123456789abcdef\n")
+	t.Setenv("APP_JWT_SECRET_FILE", secret)
+	t.Setenv("APP_DSN", "host=db dbname=app")
+	t.Setenv("APP_QUERY_TIMEOUT", "3s")
+	t.Setenv("APP_ALLOWED_ORIGINS", "https://a.example.com,https://b.example.com")
+	t.Setenv("APP_WEBSOCKET_BROKER", "postgres")
+
+	if err := Load(file, "prod"); err != nil {
+		t.Fatal(err)
+	}
+	if App.Driver != "postgres" || App.RevocationStore != "database" {
+		t.Errorf("profile not merged, driver %s revocationStore %s", App.Driver, App.RevocationStore)
+	}
+	if App.Port != "2025" || App.DefaultRole != "3" {
+		t.Errorf("base not kept, port %s defaultRole %s", App.Port, App.DefaultRole)
+	}
+	if App.JWTSecret != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("jwtSecret %q not read from file", App.JWTSecret)
+	}
+	if App.Dsn != "host=db dbname=app" || App.QueryTimeout != 3*time.Second || App.Websocket.Broker != "postgres" {
+		t.Errorf("env not applied, dsn %q queryTimeout %s broker %s", App.Dsn, App.QueryTimeout, App.Websocket.Broker)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(App.AllowedOrigins, want) {
+		t.Errorf("allowedOrigins %v want %v", App.AllowedOrigins, want)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "config.yml", base)
+	t.Setenv("APP_PORT", "http")
+	t.Setenv("APP_DSN", "")
+	t.Setenv("APP_DEFAULT_ROLE", "member")
+	t.Setenv("APP_WEBSOCKET_BROKER", "postgres")
+	before := *App
+
+	err := Load(file, "")
+	var invalid *InvalidError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Load returned %v want an *InvalidError", err)
+	}
+	// port, jwtSecret, defaultRole, dsn and the broker of sqlite
+	if len(invalid.Problems) != 5 {
+		t.Errorf("got %d problems want 5:\n%v", len(invalid.Problems), err)
+	}
+	if !reflect.DeepEqual(*App, before) {
+		t.Error("invalid config replaced App")
+	}
+}
+
+func TestLoadRejectsValueAndFile(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "config.yml", base)
+	t.Setenv("APP_JWT_SECRET", "0123456789abcdef0123456789abcdef")
+	t.Setenv("APP_JWT_SECRET_FILE", filepath.Join(dir, "missing"))
+
+	var invalid *InvalidError
+	if err := Load(file, ""); !errors.As(err, &invalid) {
+		t.Fatalf("Load returned %v want an *InvalidError", err)
+	}
+	if want := "APP_JWT_SECRET and APP_JWT_SECRET_FILE are both set"; invalid.Problems[0] != want {
+		t.Errorf("first problem %q want %q", invalid.Problems[0], want)
+	}
+}
+
+func TestLoadMissingProfile(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "config.yml", base)
+	if err := Load(file, "staging"); err == nil {
+		t.Fatal("Load accepted a missing profile file")
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// reloadable are the keys applied by Reload, other keys need a restart.
+// The running app reads them from Change instead of App, see Subscribe.
+var reloadable = map[string]bool{
+	"logLevel":       true,
+	"locale":         true,
+	"allowedOrigins": true,
+}
+
+var (
+	reloadMu    sync.Mutex
+	source      struct{ file, profile string }
+	subscribers []func(Change)
+)
+
+// Change is published after a reload, Old and New are the running config
+// before and after it
+type Change struct {
+	Old, New AppConf
+	// Changed lists the reloaded keys whose value changed
+	Changed []string
+	// Ignored lists the changed keys that only apply after a restart
+	Ignored []string
+}
+
+// Subscribe calls fn after every reload that changed a reloadable key
+func Subscribe(fn func(Change)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// Reload loads the files given to Load again and applies the reloadable keys
+// to App. A config that fails validation is rejected and App is left untouched.
+func Reload() (Change, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	next, err := load(source.file, source.profile)
+	if err != nil {
+		return Change{}, err
+	}
+	change := Change{Old: *App}
+	compare(&change, reflect.ValueOf(App).Elem(), reflect.ValueOf(next).Elem(), "")
+	change.New = *App
+	if len(change.Changed) == 0 {
+		return change, nil
+	}
+	for _, fn := range subscribers {
+		fn(change)
+	}
+	return change, nil
+}
+
+// compare copies the changed reloadable fields of next into running and
+// records the keys of changed fields. Only those fields are written, other
+// fields of App are read without locking by the running app.
+func compare(change *Change, running, next reflect.Value, prefix string) {
+	for i := 0; i < running.NumField(); i++ {
+		key := prefix + running.Type().Field(i).Tag.Get("yaml")
+		if running.Field(i).Kind() == reflect.Struct {
+			compare(change, running.Field(i), next.Field(i), key+".")
+			continue
+		}
+		if reflect.DeepEqual(running.Field(i).Interface(), next.Field(i).Interface()) {
+			continue
+		}
+		if reloadable[key] {
+			running.Field(i).Set(next.Field(i))
+			change.Changed = append(change.Changed, key)
+		} else {
+			change.Ignored = append(change.Ignored, key)
+		}
+	}
+}
+
+// Watch reloads the config when the files given to Load change, report
+// receives the result of every reload
+func Watch(report func(Change, error)) {
+	reloadMu.Lock()
+	files := []string{source.file}
+	if source.profile != "" {
+		files = append(files, ProfileFile(source.file, source.profile))
+	}
+	reloadMu.Unlock()
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+		v.OnConfigChange(func(e fsnotify.Event) {
+			report(Reload())
+		})
+		v.WatchConfig()
+	}
+}
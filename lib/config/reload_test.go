@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func loadForReload(t *testing.T) (dir string) {
+	t.Helper()
+	dir = t.TempDir()
+	t.Setenv("APP_JWT_SECRET", "0123456789abcdef0123456789abcdef")
+	file := writeFile(t, dir, "config.yml", base)
+	writeFile(t, dir, "config.dev.yml", "app:\n  logLevel: debug\n")
+	if err := Load(file, "dev"); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReload(t *testing.T) {
+	dir := loadForReload(t)
+	var events []Change
+	Subscribe(func(change Change) {
+		events = append(events, change)
+	})
+
+	writeFile(t, dir, "config.dev.yml", "app:\n  logLevel: warn\n  locale: en\n  port: 8080\n")
+	change, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"locale", "logLevel"}; !reflect.DeepEqual(change.Changed, want) {
+		t.Errorf("changed %v want %v", change.Changed, want)
+	}
+	if want := []string{"port"}; !reflect.DeepEqual(change.Ignored, want) {
+		t.Errorf("ignored %v want %v", change.Ignored, want)
+	}
+	if App.LogLevel != "warn" || App.Locale != "en" || App.Port != "2025" {
+		t.Errorf("reloaded logLevel %s locale %s port %s", App.LogLevel, App.Locale, App.Port)
+	}
+	if len(events) != 1 || events[0].Old.LogLevel != "debug" || events[0].New.LogLevel != "warn" {
+		t.Fatalf("published %+v", events)
+	}
+
+	writeFile(t, dir, "config.dev.yml", "app:\n  logLevel: verbose\n  locale: zh\n")
+	var invalid *InvalidError
+	if _, err := Reload(); !errors.As(err, &invalid) {
+		t.Fatalf("Reload returned %v want an *InvalidError", err)
+	}
+	if App.LogLevel != "warn" || App.Locale != "en" {
+		t.Errorf("rejected reload changed logLevel %s locale %s", App.LogLevel, App.Locale)
+	}
+	if len(events) != 1 {
+		t.Errorf("rejected reload published %d events", len(events)-1)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := loadForReload(t)
+	reloaded := make(chan Change, 10)
+	Watch(func(change Change, err error) {
+		if err == nil && len(change.Changed) > 0 {
+			reloaded <- change
+		}
+	})
+
+	writeFile(t, dir, "config.dev.yml", "app:\n  logLevel: error\n")
+	select {
+	case change := <-reloaded:
+		if change.New.LogLevel != "error" {
+			t.Errorf("reloaded logLevel %s want error", change.New.LogLevel)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("changing the profile file did not reload")
+	}
+}
@@ -0,0 +1,53 @@
+package lib
+
+import "net/http"
+
+// codes are the http status followed by two digits, never reuse or renumber them
+// even when the status changes, messages of every error live in messages_zh.go
+// and messages_en.go
+var (
+	ErrBadRequest          = newAppError(40000, http.StatusBadRequest, "BAD_REQUEST")
+	ErrValidation          = newAppError(40001, http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+	ErrPasswordMismatch    = newAppError(40002, http.StatusBadRequest, "PASSWORD_MISMATCH")
+	ErrOldPasswordWrong    = newAppError(40003, http.StatusBadRequest, "OLD_PASSWORD_WRONG")
+	ErrMessageToSelf       = newAppError(40004, http.StatusBadRequest, "MESSAGE_TO_SELF")
+	ErrMessageDataInvalid  = newAppError(40005, http.StatusBadRequest, "MESSAGE_DATA_INVALID")
+	ErrChannelNameInvalid  = newAppError(40006, http.StatusBadRequest, "CHANNEL_NAME_INVALID")
+	ErrPatchInvalid        = newAppError(40007, http.StatusBadRequest, "PATCH_INVALID")
+	ErrAuthHeaderMissing   = newAppError(40101, http.StatusUnauthorized, "AUTH_HEADER_MISSING")
+	ErrAuthHeaderInvalid   = newAppError(40102, http.StatusUnauthorized, "AUTH_HEADER_INVALID")
+	ErrTokenInvalid        = newAppError(40103, http.StatusUnauthorized, "TOKEN_INVALID")
+	ErrTokenRevoked        = newAppError(40104, http.StatusUnauthorized, "TOKEN_REVOKED")
+	ErrAuthInvalid         = newAppError(40105, http.StatusUnauthorized, "AUTH_INVALID")
+	ErrPasswordWrong       = newAppError(40106, http.StatusUnauthorized, "PASSWORD_WRONG")
+	ErrRefreshTokenMissing = newAppError(40107, http.StatusUnauthorized, "REFRESH_TOKEN_NOT_FOUND")
+	ErrRefreshTokenInvalid = newAppError(40108, http.StatusUnauthorized, "REFRESH_TOKEN_INVALID")
+	ErrRefreshTokenReused  = newAppError(40109, http.StatusUnauthorized, "REFRESH_TOKEN_REUSED")
+	ErrRefreshTokenExpired = newAppError(40110, http.StatusUnauthorized, "REFRESH_TOKEN_EXPIRED")
+	ErrPermissionDenied    = newAppError(40301, http.StatusForbidden, "PERMISSION_DENIED")
+	ErrUserInactive        = newAppError(40302, http.StatusForbidden, "USER_INACTIVE")
+	ErrGroupDenied         = newAppError(40303, http.StatusForbidden, "GROUP_PERMISSION_DENIED")
+	ErrChannelDenied       = newAppError(40304, http.StatusForbidden, "CHANNEL_PERMISSION_DENIED")
+	ErrUserChannelPublish  = newAppError(40305, http.StatusForbidden, "USER_CHANNEL_READ_ONLY")
+	ErrNotFound            = newAppError(40400, http.StatusNotFound, "NOT_FOUND")
+	ErrUserNotFound        = newAppError(40401, http.StatusNotFound, "USER_NOT_FOUND")
+	ErrRoleNotFound        = newAppError(40402, http.StatusNotFound, "ROLE_NOT_FOUND")
+	ErrActionNotFound      = newAppError(40403, http.StatusNotFound, "ACTION_NOT_FOUND")
+	ErrCategoryNotFound    = newAppError(40404, http.StatusNotFound, "ACTION_CATEGORY_NOT_FOUND")
+	ErrGroupNotFound       = newAppError(40405, http.StatusNotFound, "GROUP_NOT_FOUND")
+	ErrMessageNotFound     = newAppError(40406, http.StatusNotFound, "MESSAGE_NOT_FOUND")
+	ErrChannelNotFound     = newAppError(40407, http.StatusNotFound, "CHANNEL_NOT_FOUND")
+	ErrRouteNotFound       = newAppError(40408, http.StatusNotFound, "ROUTE_NOT_FOUND")
+	ErrMethodNotAllowed    = newAppError(40500, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED")
+	ErrConflict            = newAppError(40900, http.StatusConflict, "CONFLICT")
+	ErrUserExists          = newAppError(40901, http.StatusConflict, "USER_EXISTS")
+	ErrRoleExists          = newAppError(40902, http.StatusConflict, "ROLE_EXISTS")
+	ErrGroupExists         = newAppError(40903, http.StatusConflict, "GROUP_EXISTS")
+	ErrAlreadyInGroup      = newAppError(40904, http.StatusConflict, "ALREADY_IN_GROUP")
+	ErrGroupOwnerLeave     = newAppError(40905, http.StatusConflict, "GROUP_OWNER_CANNOT_LEAVE")
+	ErrPatchTestFailed     = newAppError(40906, http.StatusConflict, "PATCH_TEST_FAILED")
+	ErrUnsupportedMedia    = newAppError(41500, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE")
+	ErrFieldNotPatchable   = newAppError(42201, http.StatusUnprocessableEntity, "FIELD_NOT_PATCHABLE")
+	ErrInternal            = newAppError(50000, http.StatusInternalServerError, "INTERNAL_ERROR")
+	ErrTimeout             = newAppError(50400, http.StatusGatewayTimeout, "TIMEOUT")
+)
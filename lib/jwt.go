@@ -1,11 +1,16 @@
 package lib
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	uuid "github.com/satori/go.uuid"
 )
 
 func DecodeJWTToken(tokenStr string, secret string) (map[string]interface{}, error) {
@@ -21,14 +26,38 @@ func DecodeJWTToken(tokenStr string, secret string) (map[string]interface{}, err
 	if !token.Valid {
 		return nil, errors.New("invalid token")
 	}
-	return token.Claims.(jwt.MapClaims), nil
+	claims := token.Claims.(jwt.MapClaims)
+	// tokens without expiration are never accepted
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("token has no expiration")
+	}
+	return claims, nil
 }
 
-func GenerateJWTToken(secret string, auth map[string]interface{}) (string, error) {
-	expired := time.Now().Add(time.Hour * 24 * 30).Unix()
+func GenerateJWTToken(secret string, auth map[string]interface{}, ttl time.Duration) (string, error) {
+	now := time.Now()
+	// iat keeps milliseconds so tokens issued right after InvalidateUserTokens stay valid
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"auth": auth, "expire": expired,
+		"auth": auth,
+		"jti":  uuid.NewV4().String(),
+		"iat":  float64(now.UnixMilli()) / 1000,
+		"exp":  now.Add(ttl).Unix(),
 	})
 	tokenStr, err := token.SignedString([]byte(secret))
 	return tokenStr, err
 }
+
+// GenerateOpaqueToken returns a random url safe token and its hash for storage
+func GenerateOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(buf)
+	return raw, HashToken(raw), nil
+}
+
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
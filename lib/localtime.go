@@ -41,6 +41,10 @@ func (t LocalTime) Value() (driver.Value, error) {
 }
 
 func (t *LocalTime) Scan(v interface{}) (err error) {
+	if v == nil {
+		*t = LocalTime{}
+		return
+	}
 	value, ok := v.(time.Time)
 	if ok {
 		*t = LocalTime{Time: value}
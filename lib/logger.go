@@ -10,10 +10,18 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// logLevel is shared by every logger so it can be changed at runtime
+var logLevel = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+
+// SetLogLevel changes the level of every logger, level is debug, info, warn or error
+func SetLogLevel(level string) error {
+	return logLevel.UnmarshalText([]byte(level))
+}
+
 func NewLogger(path string) *zap.Logger {
 	encoder := getEncoder()
 	writeSyncer := getWriteSyncer(path)
-	core := zapcore.NewCore(encoder, writeSyncer, zapcore.DebugLevel)
+	core := zapcore.NewCore(encoder, writeSyncer, logLevel)
 	logger := zap.New(core, zap.AddCaller())
 	zap.ReplaceGlobals(logger)
 	return logger
@@ -33,8 +41,11 @@ func getEncoder() zapcore.Encoder {
 }
 
 func getWriteSyncer(path string) zapcore.WriteSyncer {
-	workDir, _ := os.Getwd()
-	p := filepath.Join(workDir, path)
+	p := path
+	if !filepath.IsAbs(p) {
+		workDir, _ := os.Getwd()
+		p = filepath.Join(workDir, path)
+	}
 	lumberjackLogger := &lumberjack.Logger{
 		Filename:   p,
 		MaxSize:    500, // mb
@@ -0,0 +1,26 @@
+package lib
+
+import "fmt"
+
+// messages holds the catalogue of every locale in Locales
+var messages = map[string]map[string]string{
+	"zh": zhMessages,
+	"en": enMessages,
+}
+
+// registerMessages adds the catalogues to the translators which translate validation
+// errors, so a request gets every message in the same locale
+func registerMessages() error {
+	for locale, catalogue := range messages {
+		trans, ok := universalTranslator.GetTranslator(locale)
+		if !ok {
+			return fmt.Errorf("failed to get translator of %s", locale)
+		}
+		for key, text := range catalogue {
+			if err := trans.Add(key, text, false); err != nil {
+				return fmt.Errorf("message %s of %s: %w", key, locale, err)
+			}
+		}
+	}
+	return nil
+}
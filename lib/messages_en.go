@@ -0,0 +1,49 @@
+package lib
+
+// enMessages is the English catalogue of messages keyed by the message key of each error
+var enMessages = map[string]string{
+	"bad_request":               "The request is malformed",
+	"validation_failed":         "Validation failed",
+	"password_mismatch":         "The repeated password does not match",
+	"old_password_wrong":        "The old password is incorrect",
+	"message_to_self":           "Messages cannot be sent to yourself",
+	"message_data_invalid":      "data has an invalid type",
+	"channel_name_invalid":      "The channel name is invalid",
+	"patch_invalid":             "The patch is malformed or cannot be applied",
+	"auth_header_missing":       "The Authorization header is missing",
+	"auth_header_invalid":       "The Authorization header is invalid",
+	"token_invalid":             "The token is no longer valid",
+	"token_revoked":             "The token has been revoked",
+	"auth_invalid":              "The authorization is invalid",
+	"password_wrong":            "The password is incorrect",
+	"refresh_token_not_found":   "The refresh token does not exist",
+	"refresh_token_invalid":     "The refresh token is no longer valid",
+	"refresh_token_reused":      "The refresh token has already been used",
+	"refresh_token_expired":     "The refresh token has expired",
+	"permission_denied":         "You are not allowed to do this",
+	"user_inactive":             "The user is not activated",
+	"group_permission_denied":   "You are not allowed to manage this group",
+	"channel_permission_denied": "You are not allowed to use this channel",
+	"user_channel_read_only":    "Messages cannot be published to user channels",
+	"not_found":                 "The resource does not exist",
+	"user_not_found":            "The user does not exist",
+	"role_not_found":            "The role does not exist",
+	"action_not_found":          "The action does not exist",
+	"action_category_not_found": "The action category does not exist",
+	"group_not_found":           "The group does not exist",
+	"message_not_found":         "The message does not exist",
+	"channel_not_found":         "The channel does not exist",
+	"route_not_found":           "The route does not exist",
+	"method_not_allowed":        "The method is not allowed on this route",
+	"conflict":                  "The resource already exists",
+	"user_exists":               "The user already exists",
+	"role_exists":               "The role already exists",
+	"group_exists":              "The group already exists",
+	"already_in_group":          "The user has already joined the group",
+	"group_owner_cannot_leave":  "The group owner cannot leave the group",
+	"patch_test_failed":         "A test operation of the patch failed",
+	"unsupported_media_type":    "The content type of the request is not supported",
+	"field_not_patchable":       "The fields cannot be patched",
+	"internal_error":            "Internal server error",
+	"timeout":                   "The request timed out",
+}
@@ -0,0 +1,41 @@
+package lib
+
+import "testing"
+
+func TestCataloguesCoverEveryError(t *testing.T) {
+	for _, locale := range Locales {
+		catalogue, ok := messages[locale]
+		if !ok {
+			t.Fatalf("no catalogue of %s", locale)
+		}
+		keys := make(map[string]bool)
+		for _, e := range AppErrors() {
+			keys[e.Key] = true
+			if catalogue[e.Key] == "" {
+				t.Errorf("%s misses the message of %s", locale, e.Reason)
+			}
+		}
+		for key := range catalogue {
+			if !keys[key] {
+				t.Errorf("%s has the message %s of no error", locale, key)
+			}
+		}
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	cases := map[string]string{
+		"en-GB,en;q=0.9":    "en",
+		"zh-CN,zh;q=0.9":    "zh",
+		"de;q=0.9,zh;q=0.8": "zh",
+		"de":                "",
+		"":                  "",
+		"not a language?":   "",
+	}
+	for header, want := range cases {
+		locale, ok := MatchLocale(header)
+		if locale != want || ok != (want != "") {
+			t.Errorf("MatchLocale(%q) is %q %v want %q", header, locale, ok, want)
+		}
+	}
+}
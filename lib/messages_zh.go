@@ -0,0 +1,49 @@
+package lib
+
+// zhMessages is the Chinese catalogue of messages keyed by the message key of each error
+var zhMessages = map[string]string{
+	"bad_request":               "请求参数不合法",
+	"validation_failed":         "参数校验失败",
+	"password_mismatch":         "重复密码不匹配",
+	"old_password_wrong":        "旧密码不正确",
+	"message_to_self":           "不能给自己发送消息",
+	"message_data_invalid":      "data 类型不正确",
+	"channel_name_invalid":      "频道名称不合法",
+	"patch_invalid":             "补丁格式不正确",
+	"auth_header_missing":       "授权头信息为空",
+	"auth_header_invalid":       "授权头信息不合法",
+	"token_invalid":             "令牌已失效",
+	"token_revoked":             "令牌已注销",
+	"auth_invalid":              "授权信息不合法",
+	"password_wrong":            "密码不正确",
+	"refresh_token_not_found":   "刷新令牌不存在",
+	"refresh_token_invalid":     "刷新令牌已失效",
+	"refresh_token_reused":      "刷新令牌已被重复使用",
+	"refresh_token_expired":     "刷新令牌已过期",
+	"permission_denied":         "没有操作权限",
+	"user_inactive":             "用户未激活",
+	"group_permission_denied":   "没有团队管理权限",
+	"channel_permission_denied": "没有频道权限",
+	"user_channel_read_only":    "不能向用户频道发布消息",
+	"not_found":                 "资源不存在",
+	"user_not_found":            "用户不存在",
+	"role_not_found":            "角色不存在",
+	"action_not_found":          "权限不存在",
+	"action_category_not_found": "权限分类不存在",
+	"group_not_found":           "团队不存在",
+	"message_not_found":         "消息不存在",
+	"channel_not_found":         "频道不存在",
+	"route_not_found":           "路由不存在",
+	"method_not_allowed":        "请求方法不被允许",
+	"conflict":                  "资源已存在",
+	"user_exists":               "用户已存在",
+	"role_exists":               "角色已存在",
+	"group_exists":              "团队已存在",
+	"already_in_group":          "用户已加入团队",
+	"group_owner_cannot_leave":  "团队管理员不能离开团队",
+	"patch_test_failed":         "补丁的 test 操作未通过",
+	"unsupported_media_type":    "不支持的请求内容类型",
+	"field_not_patchable":       "字段不允许修改",
+	"internal_error":            "服务器内部错误",
+	"timeout":                   "请求超时",
+}
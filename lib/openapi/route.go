@@ -0,0 +1,216 @@
+package openapi
+
+import (
+	"app/lib"
+	"app/lib/patch"
+	"app/lib/response"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const bearerAuth = "bearerAuth"
+
+// Route documents an operation replying through the response package
+type Route struct {
+	Tag         string
+	Summary     string
+	Description string
+	// Query is bound from the query string by its form tags, Body from the json body by its json tags
+	Query interface{}
+	Body  interface{}
+	// Patch holds the fields a JSON Merge Patch or a JSON Patch may change
+	Patch interface{}
+	// Data is replied on success
+	Data interface{}
+	// Status is replied on success, zero is 200 or 204 for routes replying no Data.
+	// 201 replies the Location of the created resource.
+	Status int
+	// Errors are replied besides the errors of binding, authentication and permissions
+	Errors []*lib.AppError
+	// Public routes need no access token, Action names the action required to call the route
+	Public bool
+	Action string
+}
+
+// AddRoute documents route on method and path
+func (d *Document) AddRoute(method, path string, route Route) {
+	op := &Operation{
+		Summary:     route.Summary,
+		Description: route.Description,
+		OperationID: operationID(method, path),
+		Responses:   make(map[string]*Response),
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	errs := append([]*lib.AppError{lib.ErrInternal, lib.ErrTimeout}, route.Errors...)
+	for _, name := range pathParams(path) {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+		errs = append(errs, lib.ErrBadRequest, lib.ErrNotFound)
+	}
+	if route.Query != nil {
+		t := reflect.TypeOf(route.Query)
+		for _, f := range fields(t, "form") {
+			s, required := d.fieldSchema(f.field)
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name: f.name, In: "query", Required: required, Schema: s,
+			})
+		}
+		errs = append(errs, lib.ErrBadRequest, lib.ErrValidation)
+	}
+	if route.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: d.Schema(route.Body)}},
+		}
+		errs = append(errs, lib.ErrBadRequest, lib.ErrValidation)
+	}
+	if route.Patch != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				patch.MergePatch: {Schema: d.Schema(route.Patch)},
+				patch.JSONPatch:  {Schema: &Schema{Type: "array", Items: d.Schema(patch.Operation{})}},
+			},
+		}
+		errs = append(errs, lib.ErrBadRequest, lib.ErrValidation, lib.ErrPatchInvalid, lib.ErrPatchTestFailed,
+			lib.ErrUnsupportedMedia, lib.ErrFieldNotPatchable)
+	}
+	if !route.Public {
+		op.Security = []map[string][]string{{bearerAuth: {}}}
+		d.addBearerAuth()
+		errs = append(errs, lib.ErrAuthHeaderMissing, lib.ErrAuthHeaderInvalid, lib.ErrTokenInvalid, lib.ErrTokenRevoked)
+	}
+	if route.Action != "" {
+		op.Description = strings.TrimSpace(op.Description + "\n\nRequires the action `" + route.Action + "`.")
+		errs = append(errs, lib.ErrPermissionDenied)
+	}
+	status, success := d.success(route)
+	op.Responses[strconv.Itoa(status)] = success
+	for status, group := range byStatus(errs) {
+		reply := errorResponse(group)
+		// legacy envelopes reply failures with 200 like the success
+		if same, ok := op.Responses[strconv.Itoa(status)]; ok {
+			reply = &Response{
+				Description: same.Description + ", " + reply.Description,
+				Headers:     same.Headers,
+				Content: map[string]MediaType{"application/json": {Schema: &Schema{OneOf: []*Schema{
+					same.Content["application/json"].Schema, reply.Content["application/json"].Schema,
+				}}}},
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = reply
+	}
+	d.Add(method, path, op)
+}
+
+// success describes the reply of a successful call of route
+func (d *Document) success(route Route) (int, *Response) {
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+		if route.Data == nil {
+			status = http.StatusNoContent
+		}
+	}
+	data := &Schema{Nullable: true}
+	if route.Data != nil {
+		data = d.Schema(route.Data)
+	}
+	switch {
+	case status == http.StatusSwitchingProtocols:
+		return status, &Response{Description: http.StatusText(status)}
+	case response.Legacy():
+		return http.StatusOK, jsonResponse(http.StatusText(http.StatusOK), &Schema{
+			Type:     "object",
+			Required: []string{"code", "data"},
+			Properties: map[string]*Schema{
+				"code": {Type: "integer", Enum: []interface{}{0}},
+				"data": data,
+			},
+		})
+	case status == http.StatusNoContent:
+		return status, &Response{Description: http.StatusText(status)}
+	}
+	reply := jsonResponse(http.StatusText(status), data)
+	if status == http.StatusCreated {
+		reply.Headers = map[string]*Header{
+			"Location": {Description: "path of the created resource", Schema: &Schema{Type: "string"}},
+		}
+	}
+	return status, reply
+}
+
+func (d *Document) addBearerAuth() {
+	if d.Components.SecuritySchemes == nil {
+		d.Components.SecuritySchemes = make(map[string]*SecurityScheme)
+	}
+	d.Components.SecuritySchemes[bearerAuth] = &SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+}
+
+// byStatus groups errors by their http status without duplicates, ordered by code
+func byStatus(errs []*lib.AppError) map[int][]*lib.AppError {
+	seen := make(map[string]bool)
+	groups := make(map[int][]*lib.AppError)
+	for _, e := range errs {
+		if seen[e.Reason] {
+			continue
+		}
+		seen[e.Reason] = true
+		status := response.Status(e)
+		groups[status] = append(groups[status], e)
+	}
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool { return group[i].Code < group[j].Code })
+	}
+	return groups
+}
+
+// errorResponse describes the envelope of response.Envelope replying one of errs
+func errorResponse(errs []*lib.AppError) *Response {
+	codes := make([]interface{}, len(errs))
+	reasons := make([]interface{}, len(errs))
+	names := make([]string, len(errs))
+	for i, e := range errs {
+		codes[i], reasons[i], names[i] = e.Code, e.Reason, e.Reason
+	}
+	msg := &Schema{Type: "string", Description: "message in the locale of the request"}
+	if response.Legacy() {
+		codes = []interface{}{response.LegacyErrorCode}
+		msg = &Schema{Description: "message in the locale of the request, the invalid fields of VALIDATION_FAILED"}
+	}
+	return jsonResponse(strings.Join(names, ", "), &Schema{
+		Type:     "object",
+		Required: []string{"code", "reason", "msg"},
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Enum: codes},
+			"reason":  {Type: "string", Enum: reasons},
+			"msg":     msg,
+			"details": {Description: "invalid fields of VALIDATION_FAILED or the details of the error"},
+		},
+	})
+}
+
+func jsonResponse(description string, schema *Schema) *Response {
+	return &Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// operationID names an operation after its method and path, GET /user/:id/fans is getUserIdFans
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-' || r == ':' || r == '*'
+	}) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,272 @@
+package openapi
+
+import (
+	"app/lib"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Example              interface{}        `json:"example,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+const localTimeLayout = "2006-01-02 15:04:05"
+
+// known describes types whose json is written by hand
+var known = map[reflect.Type]func() *Schema{
+	reflect.TypeOf(time.Time{}): func() *Schema {
+		return &Schema{Type: "string", Format: "date-time"}
+	},
+	reflect.TypeOf(lib.LocalTime{}): func() *Schema {
+		return &Schema{Type: "string", Example: localTimeLayout}
+	},
+	reflect.TypeOf(lib.DeletedAt{}): func() *Schema {
+		return &Schema{Type: "string", Example: localTimeLayout, Nullable: true}
+	},
+}
+
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// Schema describes the json of v, named structs are added to the components and referenced
+func (d *Document) Schema(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if build, ok := known[t]; ok {
+		return build()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := d.schemaOf(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Implements(marshalerType) {
+			return &Schema{}
+		}
+		if t.Name() == "" {
+			return d.structSchema(t, "json")
+		}
+		name := componentName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// registered before its fields so recursive types end in a reference
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t, "json")
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// componentName names a struct by its package and type, like dao.User
+func componentName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return t.Name()
+	}
+	return pkg + "." + t.Name()
+}
+
+// field is a struct field named by a json or form tag
+type field struct {
+	name  string
+	depth int
+	field reflect.StructField
+}
+
+// fields lists the fields of t the way encoding/json does, fields of embedded
+// structs are promoted unless a shallower field has the same name
+func fields(t reflect.Type, tag string) []field {
+	var found []field
+	index := make(map[string]int)
+	var walk func(t reflect.Type, depth int)
+	walk = func(t reflect.Type, depth int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+			if name == "-" || (!f.IsExported() && !f.Anonymous) {
+				continue
+			}
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				walk(ft, depth+1)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if i, ok := index[name]; ok {
+				if found[i].depth <= depth {
+					continue
+				}
+				found[i] = field{name: name, depth: depth, field: f}
+				continue
+			}
+			index[name] = len(found)
+			found = append(found, field{name: name, depth: depth, field: f})
+		}
+	}
+	walk(t, 0)
+	return found
+}
+
+func (d *Document) structSchema(t reflect.Type, tag string) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range fields(t, tag) {
+		fs, required := d.fieldSchema(f.field)
+		s.Properties[f.name] = fs
+		if required {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	return s
+}
+
+// fieldSchema describes a field with the rules of its binding tag
+func (d *Document) fieldSchema(f reflect.StructField) (*Schema, bool) {
+	s := d.schemaOf(f.Type)
+	required := applyBinding(s, f.Type, f.Tag.Get("binding"))
+	if _, opts, ok := strings.Cut(f.Tag.Get("form"), ","); ok {
+		if strings.HasPrefix(opts, "default=") {
+			s.Default = parseValue(f.Type, strings.TrimPrefix(opts, "default="))
+		}
+	}
+	return s, required
+}
+
+// applyBinding adds the validator rules of binding to s and reports whether the field is required
+func applyBinding(s *Schema, t reflect.Type, binding string) bool {
+	if binding == "" || binding == "-" {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	isString := t.Kind() == reflect.String
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		n, _ := strconv.ParseFloat(param, 64)
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, parseValue(t, v))
+			}
+		case "eqfield":
+			s.Description = "must equal " + param
+		case "len":
+			if isString {
+				s.MinLength, s.MaxLength = length(n), length(n)
+			}
+		case "lt", "lte", "max":
+			if isString {
+				if name == "lt" {
+					n--
+				}
+				s.MaxLength = length(n)
+			} else {
+				s.Maximum, s.ExclusiveMaximum = float(n), name == "lt"
+			}
+		case "gt", "gte", "min":
+			if isString {
+				if name == "gt" {
+					n++
+				}
+				s.MinLength = length(n)
+			} else {
+				s.Minimum, s.ExclusiveMinimum = float(n), name == "gt"
+			}
+		}
+	}
+	return required
+}
+
+// parseValue reads a value of a tag as the kind of t
+func parseValue(t reflect.Type, value string) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func length(n float64) *int {
+	i := int(n)
+	return &i
+}
+
+func float(n float64) *float64 {
+	return &n
+}
@@ -0,0 +1,96 @@
+package openapi
+
+import (
+	"app/lib/config"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type base struct {
+	ID      uint   `json:"id"`
+	Created string `json:"createdAt"`
+}
+
+type node struct {
+	base
+	ID       string  `json:"id"`
+	Name     string  `binding:"required,lt=200" json:"name"`
+	Kind     string  `binding:"omitempty,oneof=a b" json:"kind"`
+	Email    string  `binding:"omitempty,email" json:"email"`
+	Limit    int     `form:"limit,default=10" binding:"min=1,max=100" json:"limit"`
+	Parent   *node   `json:"parent"`
+	Children []node  `json:"children"`
+	Note     *string `json:"note"`
+	Secret   string  `json:"-"`
+}
+
+func TestSchema(t *testing.T) {
+	doc := New("test", "v1", "/")
+	ref := doc.Schema(node{})
+	if ref.Ref != "#/components/schemas/openapi.node" {
+		t.Fatalf("named struct is referenced as %q", ref.Ref)
+	}
+	s := doc.Components.Schemas["openapi.node"]
+	if got := s.Properties["id"].Type; got != "string" {
+		t.Errorf("id of node is %s, the embedded id must be shadowed", got)
+	}
+	if s.Properties["createdAt"] == nil || s.Properties["secret"] != nil || s.Properties["Secret"] != nil {
+		t.Errorf("properties are %v", reflect.ValueOf(s.Properties).MapKeys())
+	}
+	if !reflect.DeepEqual(s.Required, []string{"name"}) {
+		t.Errorf("required is %v", s.Required)
+	}
+	if name := s.Properties["name"]; name.MaxLength == nil || *name.MaxLength != 199 {
+		t.Errorf("name allows %v characters", name.MaxLength)
+	}
+	if kind := s.Properties["kind"]; !reflect.DeepEqual(kind.Enum, []interface{}{"a", "b"}) {
+		t.Errorf("kind is one of %v", kind.Enum)
+	}
+	if s.Properties["email"].Format != "email" {
+		t.Errorf("email has format %q", s.Properties["email"].Format)
+	}
+	limit := s.Properties["limit"]
+	if *limit.Minimum != 1 || *limit.Maximum != 100 || limit.Default != int64(10) {
+		t.Errorf("limit is %+v", limit)
+	}
+	if s.Properties["parent"].Ref != ref.Ref || s.Properties["children"].Items.Ref != ref.Ref {
+		t.Error("recursive fields do not reference the component")
+	}
+	if !s.Properties["note"].Nullable {
+		t.Error("pointer field is not nullable")
+	}
+}
+
+func TestAddRoute(t *testing.T) {
+	doc := New("test", "v1", "/api/v1")
+	doc.AddRoute("GET", "/user/:id/fans", Route{Query: node{}, Public: true})
+	op := (*doc.Paths["/user/{id}/fans"])["get"]
+	if op == nil || op.OperationID != "getUserIdFans" {
+		t.Fatalf("operation is %+v", op)
+	}
+	if op.Parameters[0].In != "path" || op.Parameters[0].Name != "id" {
+		t.Errorf("first parameter is %+v", op.Parameters[0])
+	}
+	if op.Security != nil || op.Responses["401"] != nil {
+		t.Error("public route requires a token")
+	}
+	if op.Responses["404"] == nil || op.Responses["400"] == nil {
+		t.Errorf("responses are %v", reflect.ValueOf(op.Responses).MapKeys())
+	}
+}
+
+func TestAddRouteLegacy(t *testing.T) {
+	config.App.LegacyEnvelope = true
+	defer func() { config.App.LegacyEnvelope = false }()
+
+	doc := New("test", "v1", "/api/v1")
+	doc.AddRoute("POST", "/node", Route{Body: node{}, Data: node{}, Status: http.StatusCreated})
+	op := (*doc.Paths["/node"])["post"]
+	if len(op.Responses) != 1 || op.Responses["200"] == nil {
+		t.Fatalf("responses are %v", reflect.ValueOf(op.Responses).MapKeys())
+	}
+	if schema := op.Responses["200"].Content["application/json"].Schema; len(schema.OneOf) != 2 {
+		t.Errorf("legacy reply is %+v", schema)
+	}
+}
@@ -0,0 +1,24 @@
+package lib
+
+import (
+	"net/url"
+	"strings"
+)
+
+// OriginAllowed reports whether origin is listed in allowed ("*" allows any),
+// an empty list allows the origin of host only
+func OriginAllowed(allowed []string, origin string, host string) bool {
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return strings.EqualFold(u.Host, host)
+	}
+	for _, v := range allowed {
+		if v == "*" || strings.EqualFold(strings.TrimRight(v, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
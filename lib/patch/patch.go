@@ -0,0 +1,186 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// bodies to the fields a resource allows to patch. The fields are a struct whose
+// json tags are the allowlist and whose binding tags validate the patched result,
+// removing a field or merging null into it sets its zero value.
+package patch
+
+import (
+	"app/lib"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	MergePatch = "application/merge-patch+json"
+	JSONPatch  = "application/json-patch+json"
+)
+
+// Patch is the body of a PATCH request and its media type
+type Patch struct {
+	MediaType string
+	Data      []byte
+}
+
+// Operation documents an operation of a JSON Patch
+type Operation struct {
+	Op    string      `binding:"required,oneof=add remove replace move copy test" json:"op"`
+	Path  string      `binding:"required" json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Apply patches fields, a pointer to a struct holding the current values of the
+// resource, and validates the result. It returns the changed values keyed by the
+// name of their struct field, ready for the Updates of gorm.
+func (p Patch) Apply(fields interface{}) (map[string]interface{}, error) {
+	v := reflect.ValueOf(fields).Elem()
+	allowed := jsonNames(v.Type())
+	doc, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var patched []byte
+	switch p.MediaType {
+	case MergePatch:
+		patched, err = p.merge(doc, allowed)
+	case JSONPatch:
+		patched, err = p.operations(doc, allowed)
+	default:
+		return nil, lib.ErrUnsupportedMedia.WithDetails([]string{MergePatch, JSONPatch})
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(patched, &keys); err != nil {
+		return nil, lib.ErrPatchInvalid.WithDetails("the patched resource is not an object")
+	}
+	if err := allow(keys, allowed); err != nil {
+		return nil, err
+	}
+	next := reflect.New(v.Type())
+	if err := json.Unmarshal(patched, next.Interface()); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(next.Interface()); err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !allowed[jsonName(f)] {
+			continue
+		}
+		if value := next.Elem().Field(i).Interface(); !reflect.DeepEqual(v.Field(i).Interface(), value) {
+			values[f.Name] = value
+		}
+	}
+	v.Set(next.Elem())
+	return values, nil
+}
+
+// merge applies a JSON Merge Patch, its members must name allowed fields
+func (p Patch) merge(doc []byte, allowed map[string]bool) ([]byte, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(p.Data, &members); err != nil {
+		return nil, lib.ErrPatchInvalid.WithDetails("a merge patch must be an object")
+	}
+	if err := allow(members, allowed); err != nil {
+		return nil, err
+	}
+	patched, err := jsonpatch.MergePatch(doc, p.Data)
+	if err != nil {
+		return nil, lib.ErrPatchInvalid.WithDetails(err.Error())
+	}
+	return patched, nil
+}
+
+// operations applies a JSON Patch, the paths of its operations must start with
+// an allowed field
+func (p Patch) operations(doc []byte, allowed map[string]bool) ([]byte, error) {
+	ops, err := jsonpatch.DecodePatch(p.Data)
+	if err != nil {
+		return nil, lib.ErrPatchInvalid.WithDetails(err.Error())
+	}
+	var denied []string
+	for _, op := range ops {
+		path, err := op.Path()
+		if err != nil {
+			return nil, lib.ErrPatchInvalid.WithDetails(err.Error())
+		}
+		pointers := []string{path}
+		if from, err := op.From(); err == nil {
+			pointers = append(pointers, from)
+		}
+		for _, pointer := range pointers {
+			// the whole document is checked once the patch is applied
+			if name := field(pointer); pointer != "" && !allowed[name] {
+				denied = append(denied, name)
+			}
+		}
+	}
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		return nil, lib.ErrFieldNotPatchable.WithDetails(denied)
+	}
+	patched, err := ops.Apply(doc)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, lib.ErrPatchTestFailed.WithDetails(err.Error())
+	}
+	if err != nil {
+		return nil, lib.ErrPatchInvalid.WithDetails(err.Error())
+	}
+	return patched, nil
+}
+
+// allow fails with the members that are not allowed fields
+func allow(members map[string]json.RawMessage, allowed map[string]bool) error {
+	var denied []string
+	for name := range members {
+		if !allowed[name] {
+			denied = append(denied, name)
+		}
+	}
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		return lib.ErrFieldNotPatchable.WithDetails(denied)
+	}
+	return nil
+}
+
+// field is the member named by the first reference token of a JSON Pointer
+func field(pointer string) string {
+	token := strings.SplitN(strings.TrimPrefix(pointer, "/"), "/", 2)[0]
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
+
+func jsonNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonName(t.Field(i)); name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// jsonName is the name of an exported field in json, empty when it is skipped
+func jsonName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,65 @@
+package patch
+
+import (
+	"app/lib"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type fields struct {
+	Name   string  `binding:"omitempty,lt=5" json:"name"`
+	Active bool    `json:"active"`
+	Parent *uint   `json:"parent"`
+	A      string  `json:"a/b"`
+	Secret string  `json:"-"`
+	Note   *string `json:"note,omitempty"`
+}
+
+func TestApply(t *testing.T) {
+	parent := uint(1)
+	current := fields{Name: "old", Active: true, Parent: &parent, Secret: "kept"}
+	values, err := Patch{MergePatch, []byte(`{"active": false, "parent": null}`)}.Apply(&current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"Active": false, "Parent": (*uint)(nil)}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("changed values are %v want %v", values, want)
+	}
+	if current.Name != "old" || current.Active || current.Parent != nil {
+		t.Errorf("patched fields are %+v", current)
+	}
+
+	values, err = Patch{JSONPatch, []byte(`[{"op": "replace", "path": "/a~1b", "value": "x"}]`)}.Apply(&current)
+	if err != nil || !reflect.DeepEqual(values, map[string]interface{}{"A": "x"}) {
+		t.Errorf("escaped pointer changed %v, %v", values, err)
+	}
+}
+
+func TestApplyRejects(t *testing.T) {
+	for _, c := range []struct {
+		patch Patch
+		want  *lib.AppError
+	}{
+		{Patch{"application/json", []byte(`{}`)}, lib.ErrUnsupportedMedia},
+		{Patch{MergePatch, []byte(`[]`)}, lib.ErrPatchInvalid},
+		{Patch{MergePatch, []byte(`{"secret": "x"}`)}, lib.ErrFieldNotPatchable},
+		{Patch{JSONPatch, []byte(`[{"op": "move", "from": "/Secret", "path": "/name"}]`)}, lib.ErrFieldNotPatchable},
+		{Patch{JSONPatch, []byte(`[{"op": "add", "path": "", "value": {"other": 1}}]`)}, lib.ErrPatchInvalid},
+		{Patch{JSONPatch, []byte(`[{"op": "test", "path": "/name", "value": "new"}]`)}, lib.ErrPatchTestFailed},
+		{Patch{JSONPatch, []byte(`[{"op": "remove", "path": "/name/0"}]`)}, lib.ErrPatchInvalid},
+	} {
+		current := fields{Name: "old"}
+		if _, err := c.patch.Apply(&current); !errors.Is(err, c.want) {
+			t.Errorf("%s %s failed with %v want %s", c.patch.MediaType, c.patch.Data, err, c.want.Reason)
+		}
+		if current.Name != "old" {
+			t.Errorf("%s changed the fields", c.patch.Data)
+		}
+	}
+	current := fields{}
+	if _, err := (Patch{MergePatch, []byte(`{"name": "too long"}`)}).Apply(&current); err == nil || current.Name != "" {
+		t.Errorf("invalid patch applied with %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+// Package response writes the replies of the api. Successful requests reply
+// their data with 200, 201 and a Location or 204 without a body, failures reply
+// the envelope of their AppError. With legacyEnvelope in the config every reply
+// is 200 like earlier releases, successes with {"code": 0, "data": ...} and
+// failures with the code LegacyErrorCode and their reason.
+package response
+
+import (
+	"app/lib"
+	"app/lib/config"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// LegacyErrorCode is the code of every failure in legacy envelopes
+const LegacyErrorCode = -2
+
+// Legacy reports whether replies use the envelope of earlier releases
+func Legacy() bool {
+	return config.App.LegacyEnvelope
+}
+
+func reply(c *gin.Context, status int, data interface{}) {
+	if Legacy() {
+		c.JSON(http.StatusOK, gin.H{"code": 0, "data": data})
+		return
+	}
+	if status == http.StatusNoContent {
+		c.Status(status)
+		return
+	}
+	c.JSON(status, data)
+}
+
+// OK replies data
+func OK(c *gin.Context, data interface{}) {
+	reply(c, http.StatusOK, data)
+}
+
+// Created replies the resource created at location, a path of the api
+func Created(c *gin.Context, location string, data interface{}) {
+	c.Header("Location", location)
+	reply(c, http.StatusCreated, data)
+}
+
+// NoContent replies an empty body
+func NoContent(c *gin.Context) {
+	reply(c, http.StatusNoContent, nil)
+}
+
+// Status is the http status replied for err, legacy envelopes reply 200
+func Status(err *lib.AppError) int {
+	if Legacy() {
+		return http.StatusOK
+	}
+	return err.Status
+}
+
+// Envelope is the body of a failed request with the message translated by trans,
+// validation errors in details are translated too and empty details are omitted
+func Envelope(trans lib.Translator, err *lib.AppError) gin.H {
+	h := gin.H{
+		"code": err.Code, "reason": err.Reason, "msg": err.Message(trans),
+	}
+	if validationErrs, ok := err.Details.(validator.ValidationErrors); ok {
+		h["details"] = lib.TranslateValidatorErrors(trans, validationErrs)
+	} else if err.Details != nil {
+		h["details"] = err.Details
+	}
+	return h
+}
+
+// legacyEnvelope is the Envelope of earlier releases, msg holds the translated
+// invalid fields of validation errors and reason is kept for clients migrating
+func legacyEnvelope(trans lib.Translator, err *lib.AppError) gin.H {
+	h := Envelope(trans, err)
+	h["code"] = LegacyErrorCode
+	if _, ok := err.Details.(validator.ValidationErrors); ok {
+		h["msg"] = h["details"]
+	}
+	return h
+}
+
+// Abort replies err in the locale of the request and stops the handlers
+func Abort(c *gin.Context, err *lib.AppError) {
+	trans := lib.TranslatorFrom(c.Request.Context())
+	if Legacy() {
+		c.AbortWithStatusJSON(Status(err), legacyEnvelope(trans, err))
+		return
+	}
+	c.AbortWithStatusJSON(Status(err), Envelope(trans, err))
+}
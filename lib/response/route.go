@@ -0,0 +1,54 @@
+package response
+
+import (
+	"app/lib"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NoRoute fails requests of unknown paths with ROUTE_NOT_FOUND
+func NoRoute(c *gin.Context) {
+	_ = c.Error(lib.ErrRouteNotFound)
+}
+
+// NoMethod fails requests of a known path with another method with
+// METHOD_NOT_ALLOWED and lists the methods of the path in Allow
+func NoMethod(routes func() gin.RoutesInfo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if allowed := allowedMethods(routes(), c.Request.URL.Path); len(allowed) > 0 {
+			c.Header("Allow", strings.Join(allowed, ", "))
+		}
+		_ = c.Error(lib.ErrMethodNotAllowed)
+	}
+}
+
+func allowedMethods(routes gin.RoutesInfo, path string) []string {
+	seen := make(map[string]bool)
+	var allowed []string
+	for _, r := range routes {
+		if !seen[r.Method] && matchPath(r.Path, path) {
+			seen[r.Method] = true
+			allowed = append(allowed, r.Method)
+		}
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// matchPath reports whether path is routed by the gin pattern, :param matches
+// a segment and *param the rest of the path
+func matchPath(pattern, path string) bool {
+	patterns := strings.Split(strings.Trim(pattern, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, p := range patterns {
+		if strings.HasPrefix(p, "*") {
+			return true
+		}
+		if i >= len(segments) || (!strings.HasPrefix(p, ":") && p != segments[i]) {
+			return false
+		}
+	}
+	return len(patterns) == len(segments)
+}
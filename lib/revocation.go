@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationStore remembers revoked token ids until the tokens expire by themselves
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, expiredAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// Revocations is the store consulted by the JWT middleware
+var Revocations RevocationStore = NewMemoryRevocationStore()
+
+type MemoryRevocationStore struct {
+	sync.RWMutex
+	revoked map[string]time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: make(map[string]time.Time)}
+}
+
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiredAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	for k, v := range s.revoked {
+		if now.After(v) {
+			delete(s.revoked, k)
+		}
+	}
+	s.revoked[jti] = expiredAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
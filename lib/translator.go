@@ -1,28 +1,101 @@
 package lib
 
 import (
+	"context"
 	"fmt"
+	"sync"
 
 	"github.com/go-playground/locales/en"
 	"github.com/go-playground/locales/zh"
 	ut "github.com/go-playground/universal-translator"
+	"golang.org/x/text/language"
 )
 
 type Translator = ut.Translator
 
-var translator Translator
+// Locales are the locales every message catalogue is written in
+var Locales = []string{"zh", "en"}
 
-func InitTranslator(locale string) (ut.Translator, error) {
-	if translator != nil {
-		return translator, nil
+var universalTranslator = ut.New(en.New(), zh.New(), en.New())
+
+var localeMatcher = language.NewMatcher([]language.Tag{language.Chinese, language.English})
+
+var (
+	translatorMu      sync.RWMutex
+	defaultTranslator Translator
+)
+
+func init() {
+	if err := registerMessages(); err != nil {
+		panic(err)
 	}
-	zhTranslator := zh.New()
-	enTranslator := en.New()
-	utTranslator := ut.New(enTranslator, zhTranslator, enTranslator)
-	trans, ok := utTranslator.GetTranslator(locale)
+}
+
+// InitTranslator selects the translator of requests without a supported
+// locale, calling it again switches the default of a running app
+func InitTranslator(locale string) (ut.Translator, error) {
+	trans, ok := universalTranslator.GetTranslator(locale)
 	if !ok {
 		return nil, fmt.Errorf("failed to get translator of %s", locale)
 	}
-	translator = trans
+	translatorMu.Lock()
+	defer translatorMu.Unlock()
+	defaultTranslator = trans
 	return trans, nil
 }
+
+func currentTranslator() Translator {
+	translatorMu.RLock()
+	defer translatorMu.RUnlock()
+	if defaultTranslator == nil {
+		return universalTranslator.GetFallback()
+	}
+	return defaultTranslator
+}
+
+// IsLocale reports whether locale has message catalogues
+func IsLocale(locale string) bool {
+	for _, l := range Locales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchLocale picks the supported locale of an Accept-Language header, ok is
+// false when the header accepts none of them
+func MatchLocale(acceptLanguage string) (locale string, ok bool) {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return "", false
+	}
+	_, index, confidence := localeMatcher.Match(tags...)
+	if confidence == language.No {
+		return "", false
+	}
+	return Locales[index], true
+}
+
+type localeKey struct{}
+
+// WithLocale returns a copy of ctx replying messages in locale
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// TranslatorFrom returns the translator of the locale carried by ctx, the
+// default locale is used when ctx carries none
+func TranslatorFrom(ctx context.Context) Translator {
+	if locale, ok := ctx.Value(localeKey{}).(string); ok {
+		if trans, found := universalTranslator.GetTranslator(locale); found {
+			return trans
+		}
+	}
+	return currentTranslator()
+}
+
+// GetTranslator returns the translator of locale or the default one
+func GetTranslator(locale string) Translator {
+	return TranslatorFrom(WithLocale(context.Background(), locale))
+}
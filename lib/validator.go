@@ -11,9 +11,10 @@ import (
 	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
 )
 
-func TranslateValidatorErrors(err validator.ValidationErrors) map[string]string {
+// TranslateValidatorErrors maps the json name of every invalid field to its message translated by trans
+func TranslateValidatorErrors(trans Translator, err validator.ValidationErrors) map[string]string {
 	errs := make(map[string]string)
-	for f, err := range err.Translate(translator) {
+	for f, err := range err.Translate(trans) {
 		stripedFieldName := f[strings.Index(f, ".")+1:]
 		errs[stripedFieldName] = err
 	}
@@ -48,7 +49,9 @@ func TranslateValidatorErrors(err validator.ValidationErrors) map[string]string
 // 	return true
 // }
 
-func RegisterValidatorTranslations(locale string) {
+// RegisterValidatorTranslations registers the messages of every locale next to
+// the catalogues of errors, the locale replied is picked per request
+func RegisterValidatorTranslations() {
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterTagNameFunc(func(fld reflect.StructField) string {
 			name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
@@ -57,16 +60,12 @@ func RegisterValidatorTranslations(locale string) {
 			}
 			return name
 		})
-		var err error
-		switch locale {
-		case "en":
-			err = enTranslations.RegisterDefaultTranslations(v, translator)
-		case "zh":
-			err = zhTranslations.RegisterDefaultTranslations(v, translator)
-		default:
-			err = enTranslations.RegisterDefaultTranslations(v, translator)
+		zhTranslator, _ := universalTranslator.GetTranslator("zh")
+		if err := zhTranslations.RegisterDefaultTranslations(v, zhTranslator); err != nil {
+			log.Fatal(err)
 		}
-		if err != nil {
+		enTranslator, _ := universalTranslator.GetTranslator("en")
+		if err := enTranslations.RegisterDefaultTranslations(v, enTranslator); err != nil {
 			log.Fatal(err)
 		}
 		// if err := v.RegisterValidation("checkDate", checkDate); err != nil {
@@ -1,109 +1,349 @@
 package ws
 
 import (
+	"app/lib"
+	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	uuid "github.com/satori/go.uuid"
 )
 
 var Upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin: checkOrigin,
+}
+
+var (
+	originsMu      sync.RWMutex
+	allowedOrigins []string
+)
+
+// SetAllowedOrigins replaces the origins allowed to connect, config.App.AllowedOrigins
+// on start and the origins of every config reload after it
+func SetAllowedOrigins(origins []string) {
+	originsMu.Lock()
+	defer originsMu.Unlock()
+	allowedOrigins = origins
+}
+
+// checkOrigin accepts requests without origin and origins allowed by lib.OriginAllowed
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	originsMu.RLock()
+	defer originsMu.RUnlock()
+	return lib.OriginAllowed(allowedOrigins, origin, r.Host)
 }
 
 var WebsocketManager = websocketManager{
-	Clients:    make([]*Client, 0),
-	Register:   make(chan *Client, 128),
-	UnRegister: make(chan *Client, 128),
+	Node:        uuid.NewV4().String(),
+	Clients:     make(map[string]map[*Client]struct{}),
+	Channels:    make(map[string]map[*Client]struct{}),
+	Register:    make(chan *Client, 128),
+	UnRegister:  make(chan *Client, 128),
+	authorizers: make(map[string]AuthorizeFunc),
+	broker:      LocalBroker{},
+	remote:      make(map[string]map[string]time.Time),
+	inbox:       make(chan *Envelope, 128),
+	outbox:      make(chan *Envelope, 1024),
 }
 
+const (
+	snapshotPeriod = 15 * time.Second
+	// remote keys missing from snapshotTTL worth of snapshots belong to a dead node
+	snapshotTTL = 3 * snapshotPeriod
+	// snapshotChunk keeps snapshot payloads below the NOTIFY size limit
+	snapshotChunk = 100
+)
+
+// websocketManager groups the connections of every user under its key,
+// a user is online as long as one of its connections is open on any node
 type websocketManager struct {
-	Clients              []*Client
+	Node                 string
+	Clients              map[string]map[*Client]struct{}
+	Channels             map[string]map[*Client]struct{}
 	Register, UnRegister chan *Client
-	Locker               sync.Mutex
+	Locker               sync.RWMutex
+	authorizers          map[string]AuthorizeFunc
+	broker               Broker
+	// remote maps keys online on other nodes to the last time each node reported them
+	remote map[string]map[string]time.Time
+	inbox  chan *Envelope
+	// outbox queues envelopes for publishBroker so a slow broker never blocks Start
+	outbox chan *Envelope
+}
+
+// SetBroker replaces the in process broker, it must be called before Start
+func (s *websocketManager) SetBroker(b Broker) {
+	s.broker = b
 }
 
 func (s *websocketManager) Start() {
+	if err := s.broker.Subscribe(s.receive); err != nil {
+		log.Printf("websocket broker: %v", err)
+	}
+	go s.publishBroker()
+	ticker := time.NewTicker(snapshotPeriod)
+	defer ticker.Stop()
 	for {
 		select {
 		case client := <-s.Register:
 			s.Locker.Lock()
-			s.Clients = append(s.Clients, client)
-			s.notifyClients(client, OnlineEvent)
+			online := s.isOnline(client.Key)
+			conns, ok := s.Clients[client.Key]
+			if !ok {
+				conns = make(map[*Client]struct{})
+				s.Clients[client.Key] = conns
+			}
+			conns[client] = struct{}{}
+			first := len(conns) == 1
+			// only the first connection brings the user online
+			if !online {
+				s.notifyClients(client.Key, OnlineEvent)
+			}
 			s.Locker.Unlock()
+			if first {
+				s.publish(&Envelope{Kind: PresenceEnvelope, Keys: []string{client.Key}, Online: true})
+			}
 		case client := <-s.UnRegister:
 			s.Locker.Lock()
-			for i := 0; i < len(s.Clients); i++ {
-				if s.Clients[i].Key == client.Key {
-					s.Clients = append(s.Clients[0:i], s.Clients[i+1:]...)
+			last := false
+			conns, ok := s.Clients[client.Key]
+			if ok {
+				if _, found := conns[client]; found {
+					delete(conns, client)
+					if len(conns) == 0 {
+						delete(s.Clients, client.Key)
+						last = true
+					}
 				}
 			}
-			s.notifyClients(client, OfflineEvent)
+			// the user goes offline after its last connection on every node closed
+			if last && !s.isOnline(client.Key) {
+				s.notifyClients(client.Key, OfflineEvent)
+			}
+			for channel := range client.channels {
+				s.leave(client, channel)
+			}
 			s.Locker.Unlock()
+			if last {
+				s.publish(&Envelope{Kind: PresenceEnvelope, Keys: []string{client.Key}, Online: false})
+			}
+		case env := <-s.inbox:
+			s.Locker.Lock()
+			s.applyPresence(env)
+			s.Locker.Unlock()
+		case <-ticker.C:
+			s.Locker.Lock()
+			s.purgeRemote()
+			keys := make([]string, 0, len(s.Clients))
+			for key := range s.Clients {
+				keys = append(keys, key)
+			}
+			s.Locker.Unlock()
+			for i := 0; i < len(keys); i += snapshotChunk {
+				end := i + snapshotChunk
+				if end > len(keys) {
+					end = len(keys)
+				}
+				s.publish(&Envelope{Kind: SnapshotEnvelope, Keys: keys[i:end]})
+			}
 		}
 	}
 }
 
-func (s *websocketManager) notifyClients(c *Client, event Event) {
-	for i := 0; i < len(s.Clients); i++ {
-		if s.Clients[i].Key != c.Key {
-			// s.Clients[i].Conn.WriteJSON(keys)
-			s.SendTo(&Message{Event: event, Data: c.Key}, s.Clients[i].Key)
+// publish queues env for the broker, it is dropped when the queue is full.
+// Lost presence envelopes are repaired by the next snapshot.
+func (s *websocketManager) publish(env *Envelope) {
+	env.Node = s.Node
+	select {
+	case s.outbox <- env:
+	default:
+		log.Printf("websocket broker: queue full, dropped %s envelope", env.Kind)
+	}
+}
+
+// publishBroker sends the queued envelopes one at a time
+func (s *websocketManager) publishBroker() {
+	for env := range s.outbox {
+		if err := s.broker.Publish(env); err != nil {
+			log.Printf("websocket broker: %v", err)
 		}
 	}
 }
 
-func (s *websocketManager) RegisterConn(key string, unsafeConn *websocket.Conn) *Client {
-	conn := &threadSafeConn{unsafeConn, sync.Mutex{}}
-	c := &Client{
-		Key: key, Conn: conn,
+// receive handles envelopes of other nodes, presence changes are serialized through Start
+func (s *websocketManager) receive(env *Envelope) {
+	if env.Node == s.Node {
+		return
+	}
+	switch env.Kind {
+	case PresenceEnvelope, SnapshotEnvelope:
+		s.inbox <- env
+	case UserEnvelope:
+		s.sendLocal(env.Message, env.Target)
+	case ChannelEnvelope:
+		s.publishLocal(env.Target, env.Message)
+	case BroadcastEnvelope:
+		s.sendAllLocal(env.Message)
+	case EvictEnvelope:
+		s.evictLocal(env.Target, env.Keys)
 	}
+}
+
+// applyPresence must be called with Locker held
+func (s *websocketManager) applyPresence(env *Envelope) {
+	now := time.Now()
+	for _, key := range env.Keys {
+		online := s.isOnline(key)
+		if env.Kind == SnapshotEnvelope || env.Online {
+			nodes, ok := s.remote[key]
+			if !ok {
+				nodes = make(map[string]time.Time)
+				s.remote[key] = nodes
+			}
+			nodes[env.Node] = now
+			if !online {
+				s.notifyClients(key, OnlineEvent)
+			}
+			continue
+		}
+		if nodes, ok := s.remote[key]; ok {
+			delete(nodes, env.Node)
+			if len(nodes) == 0 {
+				delete(s.remote, key)
+			}
+		}
+		if online && !s.isOnline(key) {
+			s.notifyClients(key, OfflineEvent)
+		}
+	}
+}
+
+// purgeRemote must be called with Locker held
+func (s *websocketManager) purgeRemote() {
+	expired := time.Now().Add(-snapshotTTL)
+	for key, nodes := range s.remote {
+		for node, seen := range nodes {
+			if seen.Before(expired) {
+				delete(nodes, node)
+			}
+		}
+		if len(nodes) == 0 {
+			delete(s.remote, key)
+			if !s.isOnline(key) {
+				s.notifyClients(key, OfflineEvent)
+			}
+		}
+	}
+}
+
+// isOnline must be called with Locker held
+func (s *websocketManager) isOnline(key string) bool {
+	return len(s.Clients[key]) > 0 || len(s.remote[key]) > 0
+}
+
+// notifyClients must be called with Locker held
+func (s *websocketManager) notifyClients(key string, event Event) {
+	msg := &Message{Event: event, Data: key}
+	for k, conns := range s.Clients {
+		if k == key {
+			continue
+		}
+		for conn := range conns {
+			conn.Send(msg)
+		}
+	}
+}
+
+func (s *websocketManager) RegisterConn(key string, conn *websocket.Conn) *Client {
+	c := newClient(key, conn)
 	s.Register <- c
+	go c.WritePump()
 	// go c.ReadPump()
 	return c
 }
 
+// FindClient returns one of the connections of key on this node
 func (s *websocketManager) FindClient(key string) *Client {
-	for _, c := range s.Clients {
-		if c.Key == key {
-			return c
-		}
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	for c := range s.Clients[key] {
+		return c
 	}
 	return nil
 }
 
+func (s *websocketManager) FindClients(key string) []*Client {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	clients := make([]*Client, 0, len(s.Clients[key]))
+	for c := range s.Clients[key] {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// FindClientKeys returns the keys online on any node
 func (s *websocketManager) FindClientKeys() []string {
-	keys := make([]string, 0)
-	for i := 0; i < len(s.Clients); i++ {
-		keys = append(keys, s.Clients[i].Key)
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	keys := make([]string, 0, len(s.Clients)+len(s.remote))
+	for key := range s.Clients {
+		keys = append(keys, key)
+	}
+	for key := range s.remote {
+		if _, ok := s.Clients[key]; !ok {
+			keys = append(keys, key)
+		}
 	}
 	return keys
 }
 
+// Contains reports whether key is online on any node
 func (s *websocketManager) Contains(key string) bool {
-	for _, c := range s.Clients {
-		if c.Key == key {
-			return true
-		}
-	}
-	return false
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	return s.isOnline(key)
 }
 
 func (s *websocketManager) Send(msg *Message) {
-	for _, c := range s.Clients {
-		c.Conn.WriteJSON(msg)
+	s.sendAllLocal(msg)
+	s.publish(&Envelope{Kind: BroadcastEnvelope, Message: msg})
+}
+
+func (s *websocketManager) sendAllLocal(msg *Message) {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	for _, conns := range s.Clients {
+		for c := range conns {
+			c.Send(msg)
+		}
 	}
 }
 
 func (s *websocketManager) SendTo(msg *Message, key string) {
-	for _, c := range s.Clients {
-		if c.Key == key {
-			c.Conn.WriteJSON(msg)
-		}
+	s.sendLocal(msg, key)
+	s.Locker.RLock()
+	_, remote := s.remote[key]
+	s.Locker.RUnlock()
+	if remote {
+		s.publish(&Envelope{Kind: UserEnvelope, Target: key, Message: msg})
+	}
+}
+
+func (s *websocketManager) sendLocal(msg *Message, key string) {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	for c := range s.Clients[key] {
+		c.Send(msg)
 	}
 }
 
-func (s *websocketManager) UnRegisterConn(key string) {
-	c := s.FindClient(key)
+func (s *websocketManager) UnRegisterConn(c *Client) {
 	s.UnRegister <- c
 }
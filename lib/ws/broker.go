@@ -0,0 +1,48 @@
+package ws
+
+type EnvelopeKind string
+
+const (
+	// PresenceEnvelope tells other nodes that keys came online or went offline on a node
+	PresenceEnvelope EnvelopeKind = "presence"
+	// SnapshotEnvelope periodically refreshes the keys online on a node
+	SnapshotEnvelope  EnvelopeKind = "snapshot"
+	UserEnvelope      EnvelopeKind = "user"
+	ChannelEnvelope   EnvelopeKind = "channel"
+	BroadcastEnvelope EnvelopeKind = "broadcast"
+	// EvictEnvelope unsubscribes the connections of Keys, or every connection without Keys, from Target
+	EvictEnvelope EnvelopeKind = "evict"
+)
+
+// Envelope is what nodes exchange through a Broker
+type Envelope struct {
+	Node    string       `json:"node"`
+	Kind    EnvelopeKind `json:"kind"`
+	Keys    []string     `json:"keys,omitempty"`
+	Online  bool         `json:"online,omitempty"`
+	Target  string       `json:"target,omitempty"`
+	Message *Message     `json:"message,omitempty"`
+}
+
+// Broker fans envelopes out to every node running the websocket manager,
+// nodes receive their own envelopes too and skip them
+type Broker interface {
+	Publish(env *Envelope) error
+	Subscribe(handler func(env *Envelope)) error
+	Close() error
+}
+
+// LocalBroker is the broker of a single process, there is nobody else to talk to
+type LocalBroker struct{}
+
+func (LocalBroker) Publish(env *Envelope) error {
+	return nil
+}
+
+func (LocalBroker) Subscribe(handler func(env *Envelope)) error {
+	return nil
+}
+
+func (LocalBroker) Close() error {
+	return nil
+}
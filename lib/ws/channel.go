@@ -0,0 +1,124 @@
+package ws
+
+import (
+	"app/lib"
+	"strings"
+)
+
+// AuthorizeFunc decides whether the user of key may subscribe or publish (event) to channel,
+// channels are named "<kind>:<id>" and id is the part after the colon
+type AuthorizeFunc func(key string, id string, event Event) error
+
+// Authorize registers the authorizer of channels of kind, channels of unknown kinds are rejected
+func (s *websocketManager) Authorize(kind string, fn AuthorizeFunc) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.authorizers[kind] = fn
+}
+
+func (s *websocketManager) authorize(c *Client, channel string, event Event) error {
+	sp := strings.SplitN(channel, ":", 2)
+	if len(sp) != 2 || sp[1] == "" {
+		return lib.ErrChannelNameInvalid
+	}
+	s.Locker.RLock()
+	fn, ok := s.authorizers[sp[0]]
+	s.Locker.RUnlock()
+	if !ok {
+		return lib.ErrChannelNotFound
+	}
+	return fn(c.Key, sp[1], event)
+}
+
+func (s *websocketManager) Subscribe(c *Client, channel string) error {
+	if err := s.authorize(c, channel, SubscribeEvent); err != nil {
+		return err
+	}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	subscribers, ok := s.Channels[channel]
+	if !ok {
+		subscribers = make(map[*Client]struct{})
+		s.Channels[channel] = subscribers
+	}
+	subscribers[c] = struct{}{}
+	c.channels[channel] = struct{}{}
+	return nil
+}
+
+func (s *websocketManager) Unsubscribe(c *Client, channel string) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.leave(c, channel)
+}
+
+// leave must be called with Locker held
+func (s *websocketManager) leave(c *Client, channel string) {
+	delete(c.channels, channel)
+	subscribers, ok := s.Channels[channel]
+	if !ok {
+		return
+	}
+	delete(subscribers, c)
+	if len(subscribers) == 0 {
+		delete(s.Channels, channel)
+	}
+}
+
+// Evict unsubscribes the connections of keys from channel on every node once
+// they lost the right to listen to it, they are told with an unsubscribed event
+func (s *websocketManager) Evict(channel string, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	s.evictLocal(channel, keys)
+	s.publish(&Envelope{Kind: EvictEnvelope, Target: channel, Keys: keys})
+}
+
+// CloseChannel unsubscribes every connection from channel on every node
+func (s *websocketManager) CloseChannel(channel string) {
+	s.evictLocal(channel, nil)
+	s.publish(&Envelope{Kind: EvictEnvelope, Target: channel})
+}
+
+// evictLocal unsubscribes the connections of keys, or all of them when keys is empty
+func (s *websocketManager) evictLocal(channel string, keys []string) {
+	evicted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		evicted[key] = true
+	}
+	msg := &Message{Event: UnsubscribedEvent, Channel: channel}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	for c := range s.Channels[channel] {
+		if len(keys) == 0 || evicted[c.Key] {
+			s.leave(c, channel)
+			c.Send(msg)
+		}
+	}
+}
+
+// Publish delivers msg to every connection subscribed to channel on any node
+func (s *websocketManager) Publish(channel string, msg *Message) {
+	s.publishLocal(channel, msg)
+	s.publish(&Envelope{Kind: ChannelEnvelope, Target: channel, Message: msg})
+}
+
+func (s *websocketManager) publishLocal(channel string, msg *Message) {
+	out := *msg
+	out.Channel = channel
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	for c := range s.Channels[channel] {
+		c.Send(&out)
+	}
+}
+
+// PublishFrom publishes data of a client to channel once it is authorized to
+func (s *websocketManager) PublishFrom(c *Client, channel string, data interface{}) error {
+	if err := s.authorize(c, channel, PublishEvent); err != nil {
+		return err
+	}
+	s.Publish(channel, &Message{Event: PublishEvent, From: c.Key, Data: data})
+	return nil
+}
@@ -1,8 +1,10 @@
 package ws
 
 import (
+	"app/lib/config"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -10,28 +12,88 @@ import (
 type Event string
 
 const (
-	OnlineEvent       Event = "online"
-	OfflineEvent      Event = "offline"
-	StatusEvent       Event = "status"
-	StatusFailEvent   Event = "statusFail"
-	StatusResultEvent Event = "statusResult"
+	OnlineEvent        Event = "online"
+	OfflineEvent       Event = "offline"
+	StatusEvent        Event = "status"
+	StatusFailEvent    Event = "statusFail"
+	StatusResultEvent  Event = "statusResult"
+	SubscribeEvent     Event = "subscribe"
+	SubscribedEvent    Event = "subscribed"
+	SubscribeFailEvent Event = "subscribeFail"
+	UnsubscribeEvent   Event = "unsubscribe"
+	UnsubscribedEvent  Event = "unsubscribed"
+	PublishEvent       Event = "publish"
+	PublishFailEvent   Event = "publishFail"
+	MessageEvent       Event = "message"
+	MessageSentEvent   Event = "messageSent"
+	MessageFailEvent   Event = "messageFail"
 )
 
 type Message struct {
-	Event Event       `json:"event"`
-	Data  interface{} `json:"data"`
+	Event   Event       `json:"event"`
+	Channel string      `json:"channel,omitempty"`
+	From    string      `json:"from,omitempty"`
+	Data    interface{} `json:"data"`
 }
 
 type Client struct {
 	Key  string
-	Conn *threadSafeConn
+	Conn *websocket.Conn
+	// channels is guarded by WebsocketManager.Locker
+	channels  map[string]struct{}
+	send      chan *Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newClient(key string, conn *websocket.Conn) *Client {
+	return &Client{
+		Key:      key,
+		Conn:     conn,
+		channels: make(map[string]struct{}),
+		send:     make(chan *Message, config.App.Websocket.SendQueueSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// Send queues msg for the writer goroutine without blocking, a full queue either drops
+// the message or disconnects the slow client according to config.App.Websocket.SlowConsumer
+func (c *Client) Send(msg *Message) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		if config.App.Websocket.SlowConsumer != "drop" {
+			c.Close()
+		}
+		return false
+	}
+}
+
+// Close stops the writer and closes the connection, the read pump then unregisters the client
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.Conn.Close()
+	})
 }
 
 func (c *Client) ReadPump(cb func(message []byte) error) {
 	defer func() {
-		WebsocketManager.UnRegisterConn(c.Key)
-		c.Conn.Close()
+		WebsocketManager.UnRegisterConn(c)
+		c.Close()
 	}()
+	conf := config.App.Websocket
+	c.Conn.SetReadLimit(conf.MaxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(conf.PongWait))
+	c.Conn.SetPongHandler(func(string) error {
+		return c.Conn.SetReadDeadline(time.Now().Add(conf.PongWait))
+	})
 	for {
 		_, raw, err := c.Conn.ReadMessage()
 		if err != nil {
@@ -49,13 +111,28 @@ func (c *Client) ReadPump(cb func(message []byte) error) {
 	}
 }
 
-type threadSafeConn struct {
-	*websocket.Conn
-	sync.Mutex
-}
-
-func (t *threadSafeConn) WriteJSON(v interface{}) error {
-	t.Lock()
-	defer t.Unlock()
-	return t.Conn.WriteJSON(v)
+// WritePump is the only writer of the connection, it flushes the send queue and pings the peer
+func (c *Client) WritePump() {
+	conf := config.App.Websocket
+	ticker := time.NewTicker(conf.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Close()
+	}()
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			c.Conn.SetWriteDeadline(time.Now().Add(conf.WriteWait))
+			if err := c.Conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(conf.WriteWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
 }
@@ -0,0 +1,133 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// notifyLimit keeps inline payloads below the 8000 bytes NOTIFY accepts
+const notifyLimit = 7900
+
+// storedTTL is how long stored envelopes are kept for the nodes to read them
+const storedTTL = time.Minute
+
+// PostgresBroker exchanges envelopes between nodes with LISTEN/NOTIFY, envelopes
+// too large for a NOTIFY payload are stored in websocket_envelopes and only their
+// id is notified
+type PostgresBroker struct {
+	dsn     string
+	channel string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	locker  sync.Mutex
+	conn    *pgx.Conn
+}
+
+func NewPostgresBroker(dsn string, channel string) *PostgresBroker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PostgresBroker{dsn: dsn, channel: channel, ctx: ctx, cancel: cancel}
+}
+
+func (b *PostgresBroker) Publish(env *Envelope) error {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	ctx, cancel := context.WithTimeout(b.ctx, 5*time.Second)
+	defer cancel()
+	if b.conn == nil || b.conn.IsClosed() {
+		conn, err := pgx.Connect(ctx, b.dsn)
+		if err != nil {
+			return err
+		}
+		b.conn = conn
+	}
+	if len(payload) <= notifyLimit {
+		_, err = b.conn.Exec(ctx, "SELECT pg_notify($1, $2)", b.channel, string(payload))
+		return err
+	}
+	if _, err := b.conn.Exec(ctx, "DELETE FROM websocket_envelopes WHERE created_at < now() - make_interval(secs => $1)", storedTTL.Seconds()); err != nil {
+		return err
+	}
+	_, err = b.conn.Exec(ctx, `WITH stored AS (INSERT INTO websocket_envelopes (payload) VALUES ($2) RETURNING id)
+		SELECT pg_notify($1, id::text) FROM stored`, b.channel, string(payload))
+	return err
+}
+
+// Subscribe listens in background and reconnects until the broker is closed
+func (b *PostgresBroker) Subscribe(handler func(env *Envelope)) error {
+	go func() {
+		for {
+			err := b.listen(handler)
+			if b.ctx.Err() != nil {
+				return
+			}
+			log.Printf("websocket broker: %v, reconnecting", err)
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-time.After(3 * time.Second):
+			}
+		}
+	}()
+	return nil
+}
+
+func (b *PostgresBroker) listen(handler func(env *Envelope)) error {
+	conn, err := pgx.Connect(b.ctx, b.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(b.ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		notification, err := conn.WaitForNotification(b.ctx)
+		if err != nil {
+			return err
+		}
+		payload := notification.Payload
+		// inline envelopes are json objects, stored ones are notified by id
+		if !strings.HasPrefix(payload, "{") {
+			id, err := strconv.ParseInt(payload, 10, 64)
+			if err != nil {
+				log.Printf("websocket broker: %v", err)
+				continue
+			}
+			if err := conn.QueryRow(b.ctx, "SELECT payload FROM websocket_envelopes WHERE id = $1", id).Scan(&payload); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					log.Printf("websocket broker: envelope %s expired", notification.Payload)
+					continue
+				}
+				return err
+			}
+		}
+		env := &Envelope{}
+		if err := json.Unmarshal([]byte(payload), env); err != nil {
+			log.Printf("websocket broker: %v", err)
+			continue
+		}
+		handler(env)
+	}
+}
+
+func (b *PostgresBroker) Close() error {
+	b.cancel()
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	if b.conn != nil {
+		return b.conn.Close(context.Background())
+	}
+	return nil
+}
@@ -11,10 +11,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -30,37 +33,105 @@ func setupApp() *gin.Engine {
 	app.Use(middleware.Logger(apiLogger))
 	app.Use(middleware.Recovery(appLogger))
 	app.Use(middleware.Error())
-	app.Use(middleware.Cors())
+	app.Use(middleware.Cors(config.App.AllowedOrigins))
+	app.Use(middleware.Locale())
+	app.Use(middleware.QueryTimeout(config.App.QueryTimeout))
+	lib.SetLogLevel(config.App.LogLevel)
 	lib.InitTranslator(config.App.Locale)
-	lib.RegisterValidatorTranslations(config.App.Locale)
-	dao.Init(config.App.Dsn)
+	lib.RegisterValidatorTranslations()
+	ws.SetAllowedOrigins(config.App.AllowedOrigins)
+	dao.Init(config.App.Driver, config.App.Dsn)
+	if config.App.AutoMigrate {
+		if _, err := dao.MigrateUp(context.Background(), 0); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if config.App.RevocationStore == "database" {
+		lib.Revocations = dao.RevocationStore{}
+	}
 	api.ApplyRoutes(app)
-	go ws.WebsocketManager.Start()
 	return app
 }
 
+var startOnce sync.Once
+
+// start follows config reloads and runs the websocket manager, apps built by setupApp
+// share both so they are started once per process however many apps are built
+func start() {
+	startOnce.Do(func() {
+		config.Subscribe(func(change config.Change) {
+			lib.SetLogLevel(change.New.LogLevel)
+			lib.InitTranslator(change.New.Locale)
+			ws.SetAllowedOrigins(change.New.AllowedOrigins)
+		})
+		if config.App.Websocket.Broker == "postgres" {
+			ws.WebsocketManager.SetBroker(ws.NewPostgresBroker(config.App.Dsn, "websocket"))
+		}
+		go ws.WebsocketManager.Start()
+	})
+}
+
 var version = ""
 
 var printVersion bool
 
-func init() {
-	flag.BoolVar(&printVersion, "version", false, "print program build version")
-	flag.Parse()
-}
+// configFile and profile are read by every command, APP_CONFIG and APP_PROFILE set their defaults
+var configFile, profile string
 
 func main() {
+	flag.BoolVar(&printVersion, "version", false, "print program build version")
+	flag.StringVar(&configFile, "config", envOr("APP_CONFIG", "config.yml"), "config file")
+	flag.StringVar(&profile, "profile", os.Getenv("APP_PROFILE"), "profile like dev, test or prod merged from config.<profile>.yml next to the config file")
+	flag.Usage = usage
+	flag.Parse()
 	if printVersion {
 		println(version)
 		os.Exit(0)
 	}
+	if err := run(flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// reportReload logs the result of a config reload triggered by a file change or SIGHUP
+func reportReload(change config.Change, err error) {
+	if err != nil {
+		log.Printf("config reload rejected, keeping the running config: %s\n", err)
+		return
+	}
+	if len(change.Changed) > 0 {
+		log.Printf("config reloaded: %s\n", strings.Join(change.Changed, ", "))
+	}
+	if len(change.Ignored) > 0 {
+		log.Printf("config changes of %s apply after a restart\n", strings.Join(change.Ignored, ", "))
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Parse(args)
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
-	config.Read()
+	if err := config.Load(configFile, profile); err != nil {
+		return err
+	}
 	app := setupApp()
+	start()
 
+	// requests still running when shutdown gives up get their queries cancelled
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", config.App.Port),
-		Handler: app,
+		Addr:        fmt.Sprintf(":%s", config.App.Port),
+		Handler:     app,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
 
 	go func() {
@@ -68,13 +139,25 @@ func main() {
 			log.Fatalf("failed to listen: %s\n", err)
 		}
 	}()
+
+	config.Watch(reportReload)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	go func() {
+		for range hangup {
+			reportReload(config.Reload())
+		}
+	}()
 	<-ctx.Done()
 	stop()
 	log.Println("shutdown gracefully, press ctrl+c force shutdown")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("failed to shutdown server: ", err)
+		cancelRequests()
+		return fmt.Errorf("failed to shutdown server: %w", err)
 	}
 	log.Println("server exiting")
+	return nil
 }
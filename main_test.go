@@ -0,0 +1,149 @@
+package main
+
+import (
+	"app/lib"
+	"app/lib/config"
+	"app/lib/response"
+	"app/repository/dao"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var app *gin.Engine
+
+// TestMain boots the app once against a seeded sqlite database in memory,
+// tests create their own users, roles and groups with the fixtures
+func TestMain(m *testing.M) {
+	logDir, err := os.MkdirTemp("", "app-test-log")
+	if err != nil {
+		log.Fatal(err)
+	}
+	*config.App = config.AppConf{
+		Locale:          "zh",
+		LogDir:          logDir,
+		JWTSecret:       "test-secret",
+		GroupAdminRole:  "2",
+		DefaultRole:     "3",
+		Driver:          "sqlite",
+		Dsn:             ":memory:",
+		QueryTimeout:    10 * time.Second,
+		AutoMigrate:     true,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+		RevocationStore: "memory",
+		Websocket: config.WebsocketConf{
+			PingPeriod:     9 * time.Second,
+			PongWait:       10 * time.Second,
+			WriteWait:      10 * time.Second,
+			MaxMessageSize: 64 * 1024,
+			SendQueueSize:  256,
+			SlowConsumer:   "disconnect",
+			Broker:         "local",
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	app = setupApp()
+	start()
+	if err := dao.Seed(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	code := m.Run()
+	dao.Close()
+	os.RemoveAll(logDir)
+	os.Exit(code)
+}
+
+// envelope is the reply of the api, Data holds the body of a success and the
+// other fields the envelope of a failure or of a legacy reply
+type envelope struct {
+	Code    int             `json:"code"`
+	Reason  string          `json:"reason"`
+	Msg     any             `json:"msg"`
+	Details any             `json:"details"`
+	Data    json.RawMessage `json:"data"`
+	status  int
+	header  http.Header
+}
+
+// call sends body as json to the api, token is sent as the bearer token when not empty
+func call(t *testing.T, method, path, token string, body any) envelope {
+	t.Helper()
+	return callWithHeader(t, method, path, token, body, nil)
+}
+
+// callWithHeader calls the api like call with extra request headers
+func callWithHeader(t *testing.T, method, path, token string, body any, header map[string]string) envelope {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(buf)
+	}
+	req := httptest.NewRequest(method, "/api/v1/"+path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	var reply envelope
+	if w.Code >= http.StatusBadRequest || config.App.LegacyEnvelope {
+		if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, path, err, w.Body.String())
+		}
+	} else {
+		reply.Data = w.Body.Bytes()
+	}
+	reply.status, reply.header = w.Code, w.Header()
+	return reply
+}
+
+// ok calls the api and fails the test unless it succeeds, data is decoded into out when given
+func ok(t *testing.T, method, path, token string, body any, out any) {
+	t.Helper()
+	reply := call(t, method, path, token, body)
+	if reply.status >= http.StatusMultipleChoices || reply.Code != 0 {
+		t.Fatalf("%s %s: status %d code %d %s, %s %v", method, path, reply.status, reply.Code, reply.Reason, reply.Msg, reply.Details)
+	}
+	if out != nil {
+		if len(reply.Data) == 0 {
+			t.Fatalf("%s %s: replied %d without a body", method, path, reply.status)
+		}
+		if err := json.Unmarshal(reply.Data, out); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, path, err, reply.Data)
+		}
+	}
+}
+
+// fails calls the api and fails the test unless it replies want
+func fails(t *testing.T, want *lib.AppError, method, path, token string, body any) envelope {
+	t.Helper()
+	reply := call(t, method, path, token, body)
+	code := want.Code
+	if response.Legacy() {
+		code = response.LegacyErrorCode
+	}
+	if status := response.Status(want); reply.status != status || reply.Code != code || reply.Reason != want.Reason {
+		t.Fatalf("%s %s: status %d code %d %s want %d %d %s, %s %v", method, path,
+			reply.status, reply.Code, reply.Reason, status, code, want.Reason, reply.Msg, reply.Details)
+	}
+	return reply
+}
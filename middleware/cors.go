@@ -1,15 +1,32 @@
 package middleware
 
-import "github.com/gin-gonic/gin"
+import (
+	"app/lib"
+	"app/lib/config"
+	"sync/atomic"
 
-func Cors() gin.HandlerFunc {
+	"github.com/gin-gonic/gin"
+)
+
+// Cors answers origins allowed by lib.OriginAllowed, the allowed origins
+// follow config reloads
+func Cors(allowed []string) gin.HandlerFunc {
+	var origins atomic.Value
+	origins.Store(allowed)
+	config.Subscribe(func(change config.Change) {
+		origins.Store(change.New.AllowedOrigins)
+	})
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", c.Request.Header.Get("Origin"))
-		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Access-Control-Allow-Origin, Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-NT-Captcha")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		origin := c.Request.Header.Get("Origin")
+		if origin != "" && lib.OriginAllowed(origins.Load().([]string), origin, c.Request.Host) {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Max-Age", "86400")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE, UPDATE")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Access-Control-Allow-Origin, Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-NT-Captcha")
+			c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(200)
 		} else {
@@ -2,12 +2,47 @@ package middleware
 
 import (
 	"app/lib"
-	"net/http"
+	"app/lib/response"
+	"app/repository/dao"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
 )
 
+// ResolveError resolves the error replied for err, errors outside the catalogue
+// are internal errors whose text is only logged. Validation errors are kept as
+// details so response.Envelope translates them in the locale of the reply.
+func ResolveError(err error) *lib.AppError {
+	var appErr *lib.AppError
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var numErr *strconv.NumError
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.As(err, &validationErrs):
+		return lib.ErrValidation.WithDetails(validationErrs)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return lib.ErrNotFound.Wrap(err)
+	case dao.IsUniqueViolation(err):
+		return lib.ErrConflict.Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return lib.ErrTimeout.Wrap(err)
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.As(err, &numErr),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return lib.ErrBadRequest.WithDetails(err.Error())
+	}
+	return lib.ErrInternal.Wrap(err)
+}
+
+// Error replies the last error of the request, the Logger middleware logs all of them
 func Error() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
@@ -15,17 +50,6 @@ func Error() gin.HandlerFunc {
 		if len(errs) == 0 {
 			return
 		}
-		last := errs.Last()
-		err, ok := last.Err.(validator.ValidationErrors)
-		if ok {
-			c.JSON(http.StatusOK, &gin.H{
-				"code": -2, "msg": lib.TranslateValidatorErrors(err),
-			})
-		} else {
-			c.JSON(http.StatusOK, &gin.H{
-				"code": -2, "msg": last.Error(),
-			})
-		}
-		c.Abort()
+		response.Abort(c, ResolveError(errs.Last().Err))
 	}
 }
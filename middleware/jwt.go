@@ -3,11 +3,15 @@ package middleware
 import (
 	"app/lib"
 	"app/lib/config"
+	"app/repository/dao"
+	"context"
 	"errors"
+	"math"
 	"regexp"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func isMethodAllowed(method string, methods []string) bool {
@@ -19,22 +23,64 @@ func isMethodAllowed(method string, methods []string) bool {
 	return false
 }
 
-func matchRules(rules map[string]string, target string, method string) (bool, error) {
+// MatchRules reports whether target matches a rule of rules for method, rules
+// map a regular expression to the methods joined by |. Every rule is tried so
+// the answer does not depend on the order of the map.
+func MatchRules(rules map[string]string, target string, method string) (bool, error) {
 	for rule, m := range rules {
 		matched, err := regexp.MatchString(rule, target)
 		if err != nil {
 			return false, err
 		}
-		if matched {
-			return isMethodAllowed(strings.ToLower(method), strings.Split(strings.ToLower(m), "|")), nil
+		if matched && isMethodAllowed(strings.ToLower(method), strings.Split(strings.ToLower(m), "|")) {
+			return true, nil
 		}
 	}
 	return false, nil
 }
 
+// verifyTokenState rejects tokens revoked by logout or issued before the user's tokens were invalidated
+func verifyTokenState(ctx context.Context, token map[string]interface{}) error {
+	jti, _ := token["jti"].(string)
+	revoked, err := lib.Revocations.IsRevoked(ctx, jti)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return lib.ErrTokenRevoked
+	}
+	auth, _ := token["auth"].(map[string]interface{})
+	id, _ := auth["id"].(string)
+	validAfter, err := dao.TokensValidAfter(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return lib.ErrTokenInvalid
+		}
+		return err
+	}
+	iat, _ := token["iat"].(float64)
+	if int64(math.Round(iat*1000)) <= validAfter.UnixMilli() {
+		return lib.ErrTokenInvalid
+	}
+	return nil
+}
+
+// VerifyToken decodes an access token and makes sure it is still in force
+func VerifyToken(ctx context.Context, tokenStr string) (map[string]interface{}, error) {
+	token, err := lib.DecodeJWTToken(tokenStr, config.App.JWTSecret)
+	if err != nil {
+		return nil, lib.ErrTokenInvalid.Wrap(err)
+	}
+	if err := verifyTokenState(ctx, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
 func JWT(unless map[string]string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		matched, err := matchRules(unless, c.Request.URL.String(), c.Request.Method)
+		// the query string never makes a route public
+		matched, err := MatchRules(unless, c.Request.URL.Path, c.Request.Method)
 		if err != nil {
 			_ = c.Error(err)
 			c.Abort()
@@ -46,25 +92,28 @@ func JWT(unless map[string]string) gin.HandlerFunc {
 		}
 		headerStr := c.Request.Header.Get("Authorization")
 		if headerStr == "" {
-			_ = c.Error(errors.New("授权头信息为空"))
+			_ = c.Error(lib.ErrAuthHeaderMissing)
 			c.Abort()
 			return
 		}
 		sp := strings.Split(headerStr, "Bearer ")
 		if len(sp) <= 1 {
-			_ = c.Error(errors.New("授权头信息不合法"))
+			_ = c.Error(lib.ErrAuthHeaderInvalid)
 			c.Abort()
 			return
 		}
 		tokenStr := sp[1]
 		tokenStr = strings.TrimSpace(tokenStr)
-		token, err := lib.DecodeJWTToken(tokenStr, config.App.JWTSecret)
+		token, err := VerifyToken(c.Request.Context(), tokenStr)
 		if err != nil {
 			_ = c.Error(err)
 			c.Abort()
 			return
 		}
-		c.Set("auth", token["auth"])
+		auth, _ := token["auth"].(map[string]interface{})
+		PreferLocale(c, auth)
+		c.Set("auth", auth)
+		c.Set("claims", token)
 		c.Next()
 	}
 }
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"app/lib"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Locale replies the request in the supported locale of its Accept-Language
+// header, the locale of the config is used when it accepts none of them
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		if locale, ok := lib.MatchLocale(c.GetHeader("Accept-Language")); ok {
+			setLocale(c, locale)
+		}
+		c.Next()
+	}
+}
+
+// PreferLocale replies the request in the locale preferred by the signed in
+// user of auth, which overrides Accept-Language
+func PreferLocale(c *gin.Context, auth map[string]interface{}) {
+	if locale, _ := auth["locale"].(string); lib.IsLocale(locale) {
+		setLocale(c, locale)
+	}
+}
+
+func setLocale(c *gin.Context, locale string) {
+	c.Request = c.Request.WithContext(lib.WithLocale(c.Request.Context(), locale))
+	c.Header("Content-Language", locale)
+}
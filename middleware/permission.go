@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"app/lib"
+	"app/lib/response"
+	"app/repository/dao"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func authRoleID(c *gin.Context) (uint, bool) {
+	auth := c.GetStringMap("auth")
+	switch roleID := auth["roleID"].(type) {
+	case float64:
+		return uint(roleID), roleID > 0
+	case uint:
+		return roleID, roleID > 0
+	case int:
+		return uint(roleID), roleID > 0
+	}
+	return 0, false
+}
+
+// Permitted reports whether the caller's role is granted every given action value
+func Permitted(c *gin.Context, values ...string) (bool, error) {
+	roleID, ok := authRoleID(c)
+	if !ok {
+		return false, nil
+	}
+	granted, err := dao.RolePermissions(c.Request.Context(), roleID)
+	if err != nil {
+		return false, err
+	}
+	for _, value := range values {
+		if !granted[value] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func checkPermissions(c *gin.Context, values []string) {
+	permitted, err := Permitted(c, values...)
+	if err != nil {
+		_ = c.Error(err)
+		c.Abort()
+		return
+	}
+	if !permitted {
+		response.Abort(c, lib.ErrPermissionDenied)
+		return
+	}
+	c.Next()
+}
+
+// Require rejects callers whose role is not granted every given action value
+func Require(values ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checkPermissions(c, values)
+	}
+}
+
+// Permission looks up the action values required by the matched route,
+// rules are keyed by "METHOD /full/path" and separate values by "|". Routes of
+// own need no action when their :id is the id of the caller.
+func Permission(rules map[string]string, own map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		rule, ok := rules[route]
+		if !ok {
+			c.Next()
+			return
+		}
+		if id, _ := c.GetStringMap("auth")["id"].(string); own[route] && id != "" && id == c.Param("id") {
+			c.Next()
+			return
+		}
+		checkPermissions(c, strings.Split(rule, "|"))
+	}
+}
@@ -1,8 +1,9 @@
 package middleware
 
 import (
+	"app/lib"
+	"app/lib/response"
 	"net"
-	"net/http"
 	"net/http/httputil"
 	"os"
 	"strings"
@@ -38,9 +39,7 @@ func Recovery(logger *zap.Logger) gin.HandlerFunc {
 					zap.Any("error", err),
 					zap.String("request", string(httpRequest)),
 				)
-				c.JSON(http.StatusOK, &gin.H{
-					"code": -1, "msg": err,
-				})
+				response.Abort(c, lib.ErrInternal)
 			}
 		}()
 		c.Next()
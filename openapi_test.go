@@ -0,0 +1,135 @@
+package main
+
+import (
+	v1 "app/api/v1"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRoutesDocumented(t *testing.T) {
+	_, problems := v1.Document(app.Routes(), "/api/v1")
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+}
+
+func get(t *testing.T, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s replied %d", path, w.Code)
+	}
+	return w
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Description string `json:"description"`
+			Security    []any  `json:"security"`
+			Parameters  []struct {
+				Name     string `json:"name"`
+				In       string `json:"in"`
+				Required bool   `json:"required"`
+				Schema   struct {
+					Default any `json:"default"`
+				} `json:"schema"`
+			} `json:"parameters"`
+			Responses map[string]any `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	body := get(t, "/api/openapi.json").Body.String()
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("openapi version is %q", doc.OpenAPI)
+	}
+
+	operations := make(map[string]bool)
+	for _, r := range app.Routes() {
+		if !strings.HasPrefix(r.Path, "/api/v1/") {
+			continue
+		}
+		path := "/" + strings.TrimPrefix(r.Path, "/api/v1/")
+		for _, param := range []string{"id", "userID"} {
+			path = strings.ReplaceAll(path, ":"+param, "{"+param+"}")
+		}
+		op, ok := doc.Paths[path][strings.ToLower(r.Method)]
+		if !ok {
+			t.Errorf("%s %s is missing from the document", r.Method, path)
+			continue
+		}
+		if operations[op.OperationID] {
+			t.Errorf("operation id %s is used twice", op.OperationID)
+		}
+		operations[op.OperationID] = true
+		success := false
+		for _, status := range []string{"101", "200", "201", "204"} {
+			if _, ok := op.Responses[status]; ok {
+				success = true
+			}
+		}
+		if !success {
+			t.Errorf("%s %s documents no success reply", r.Method, path)
+		}
+	}
+
+	if op := doc.Paths["/public/user"]["get"]; op.Security != nil {
+		t.Errorf("public route requires %v", op.Security)
+	} else if len(op.Parameters) == 0 {
+		t.Error("query of GET /public/user is not documented")
+	} else {
+		for _, p := range op.Parameters {
+			if p.Name == "page" && (p.In != "query" || p.Schema.Default != float64(1)) {
+				t.Errorf("page parameter is %+v", p)
+			}
+		}
+	}
+	if op := doc.Paths["/ping"]["get"]; op.Security != nil {
+		t.Errorf("GET /ping requires %v", op.Security)
+	}
+	if op := doc.Paths["/public/login"]["post"]; op.Security != nil {
+		t.Errorf("POST /public/login requires %v", op.Security)
+	}
+	if op := doc.Paths["/role/{id}"]["put"]; op.Security == nil || !strings.Contains(op.Description, "ROLE_WRITE") {
+		t.Errorf("PUT /role/{id} misses its token or action: %+v", op)
+	}
+	if _, ok := doc.Paths["/role/{id}"]["put"].Responses["403"]; !ok {
+		t.Error("PUT /role/{id} documents no PERMISSION_DENIED")
+	}
+
+	// every reference resolves to a schema of the components
+	for _, ref := range strings.Split(body, `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.Index(ref, `"`)]
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is referenced but missing", name)
+		}
+	}
+	var register struct {
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(doc.Components.Schemas["dto.RegisterUser"], &register); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(register.Required, ",") != "username,password,repeatPassword" {
+		t.Errorf("required fields of dto.RegisterUser are %v", register.Required)
+	}
+}
+
+func TestSwaggerUI(t *testing.T) {
+	page := get(t, "/api/docs/").Body.String()
+	if !strings.Contains(page, "swagger-ui-bundle.js") || !strings.Contains(page, "openapi.json") {
+		t.Fatalf("swagger ui page is %s", page)
+	}
+	get(t, "/api/docs/swagger-ui-bundle.js")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"app/lib"
+	"app/lib/patch"
+	"app/repository/dao"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// patches sends body as a patch of mediaType and fails the test unless it replies want
+func patches(t *testing.T, want *lib.AppError, mediaType, path, token string, body any) envelope {
+	t.Helper()
+	reply := callWithHeader(t, "PATCH", path, token, body, map[string]string{"Content-Type": mediaType})
+	if want == nil && reply.status != http.StatusOK {
+		t.Fatalf("PATCH %s: status %d %s, %s %v", path, reply.status, reply.Reason, reply.Msg, reply.Details)
+	}
+	if want != nil && (reply.status != want.Status || reply.Reason != want.Reason) {
+		t.Fatalf("PATCH %s: status %d %s want %d %s, %s %v", path, reply.status, reply.Reason, want.Status, want.Reason, reply.Msg, reply.Details)
+	}
+	return reply
+}
+
+func TestMergePatchClearsFields(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	user := newUser(t, memberRoleID)
+	if _, err := user.Update(context.Background(), map[string]interface{}{"nickname": "nick", "phone": "123"}); err != nil {
+		t.Fatal(err)
+	}
+
+	patches(t, nil, patch.MergePatch, "user/"+user.ID, adminToken, map[string]interface{}{
+		"nickname": nil, "isActived": false,
+	})
+	found := findUser(t, user.ID)
+	if found.Nickname != "" || found.IsActived || found.Phone != "123" {
+		t.Fatalf("patched user is %q %v %q", found.Nickname, found.IsActived, found.Phone)
+	}
+	if found.TokensValidAfter.IsZero() {
+		t.Error("tokens of the deactivated user are still valid")
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	role := newRole(t)
+	path := fmt.Sprintf("role/%d", role.ID)
+
+	patches(t, nil, patch.JSONPatch, path, adminToken, []map[string]interface{}{
+		{"op": "test", "path": "/isActived", "value": true},
+		{"op": "replace", "path": "/isActived", "value": false},
+		{"op": "add", "path": "/description", "value": "patched"},
+	})
+	found, err := dao.Roles.Find(context.Background(), role.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.IsActived || found.Description != "patched" {
+		t.Fatalf("patched role is %v %q", found.IsActived, found.Description)
+	}
+	patches(t, lib.ErrPatchTestFailed, patch.JSONPatch, path, adminToken, []map[string]interface{}{
+		{"op": "test", "path": "/isActived", "value": true},
+	})
+	patches(t, lib.ErrPatchInvalid, patch.JSONPatch, path, adminToken, map[string]string{"op": "remove"})
+}
+
+func TestPatchAllowlist(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	user := newUser(t, memberRoleID)
+	reply := patches(t, lib.ErrFieldNotPatchable, patch.MergePatch, "user/"+user.ID, adminToken, map[string]interface{}{
+		"username": "taken", "nickname": "nick",
+	})
+	if fmt.Sprint(reply.Details) != "[username]" {
+		t.Errorf("details are %v", reply.Details)
+	}
+	patches(t, lib.ErrFieldNotPatchable, patch.JSONPatch, "user/"+user.ID, adminToken, []map[string]interface{}{
+		{"op": "copy", "from": "/password", "path": "/nickname"},
+	})
+	patches(t, lib.ErrUnsupportedMedia, "application/json", "user/"+user.ID, adminToken, map[string]string{"nickname": "nick"})
+	if found := findUser(t, user.ID); found.Nickname != "" || found.Username == "taken" {
+		t.Fatalf("rejected patches changed the user to %q %q", found.Username, found.Nickname)
+	}
+}
+
+func TestPatchValidation(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	user := newUser(t, memberRoleID)
+	reply := patches(t, lib.ErrValidation, patch.MergePatch, "user/"+user.ID, adminToken, map[string]string{"email": "not-an-email"})
+	if details, ok := reply.Details.(map[string]interface{}); !ok || details["email"] == nil {
+		t.Errorf("details are %v", reply.Details)
+	}
+
+	action := newAction(t, unique("PATCH"))
+	path := "action/" + action.ID
+	patches(t, lib.ErrValidation, patch.JSONPatch, path, adminToken, []map[string]interface{}{
+		{"op": "remove", "path": "/categoryID"},
+	})
+	patches(t, lib.ErrCategoryNotFound, patch.MergePatch, path, adminToken, map[string]interface{}{"categoryID": 1 << 30})
+}
+
+func TestPatchGroup(t *testing.T) {
+	owner := newUser(t, memberRoleID)
+	group := newGroup(t, &owner)
+	industry := uint(1)
+	if _, err := group.Update(context.Background(), map[string]interface{}{"industry_id": industry}); err != nil {
+		t.Fatal(err)
+	}
+	patches(t, lib.ErrGroupDenied, patch.MergePatch, "group/"+group.ID, login(t, newUser(t, memberRoleID)), map[string]string{"logo": "x"})
+	patches(t, nil, patch.MergePatch, "group/"+group.ID, login(t, owner), map[string]interface{}{"industryID": nil})
+	if found := findGroup(t, group.ID); found.IndustryID != nil {
+		t.Fatalf("industry of the group is %d", *found.IndustryID)
+	}
+}
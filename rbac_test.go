@@ -0,0 +1,95 @@
+package main
+
+import (
+	"app/lib"
+	"app/lib/patch"
+	"fmt"
+	"testing"
+)
+
+func TestGrantAndRevokeAction(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	roleWrite := seededAction(t, "ROLE_WRITE")
+	role := newRole(t)
+	token := login(t, newUser(t, role.ID))
+
+	fails(t, lib.ErrPermissionDenied, "POST", "role", token, map[string]string{"name": unique("role")})
+
+	op := map[string]interface{}{"roleID": role.ID, "actionID": roleWrite.ID}
+	ok(t, "POST", "role/action", adminToken, op, nil)
+	ok(t, "POST", "role", token, map[string]string{"name": unique("role")}, nil)
+
+	ok(t, "DELETE", "role/action", adminToken, op, nil)
+	fails(t, lib.ErrPermissionDenied, "POST", "role", token, map[string]string{"name": unique("role")})
+}
+
+func TestChangeRoleActions(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	role := newRole(t, seededAction(t, "ROLE_WRITE"))
+	first, second := newAction(t, unique("FIRST_")), newAction(t, unique("SECOND_"))
+
+	ok(t, "PUT", "role/action", adminToken, map[string]interface{}{
+		"roleID": role.ID, "actionID": first.ID + "," + second.ID,
+	}, nil)
+	var found struct {
+		Actions []struct {
+			Value string `json:"value"`
+		} `json:"actions"`
+	}
+	ok(t, "GET", fmt.Sprintf("public/role/%d", role.ID), "", nil, &found)
+	granted := make(map[string]bool)
+	for _, action := range found.Actions {
+		granted[action.Value] = true
+	}
+	if len(granted) != 2 || !granted[first.Value] || !granted[second.Value] {
+		t.Fatalf("role actions are %v want %s and %s", granted, first.Value, second.Value)
+	}
+}
+
+func TestGrantAndRevokeRole(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	user := newUser(t, memberRoleID)
+	self := map[string]string{"userID": user.ID}
+
+	fails(t, lib.ErrPermissionDenied, "POST", "active/user", login(t, user), self)
+
+	op := map[string]interface{}{"userID": user.ID, "roleID": adminRoleID}
+	ok(t, "POST", "user/role", adminToken, op, nil)
+	if roleID := findUser(t, user.ID).RoleID; roleID == nil || *roleID != adminRoleID {
+		t.Fatalf("granted user has role %v want %d", roleID, adminRoleID)
+	}
+	ok(t, "POST", "active/user", login(t, user), self, nil)
+
+	ok(t, "DELETE", "user/role", adminToken, op, nil)
+	if roleID := findUser(t, user.ID).RoleID; roleID != nil {
+		t.Fatalf("revoked user still has role %d", *roleID)
+	}
+	// signing in again falls back to the default role
+	fails(t, lib.ErrPermissionDenied, "POST", "active/user", login(t, user), self)
+	if roleID := findUser(t, user.ID).RoleID; roleID == nil || *roleID != memberRoleID {
+		t.Fatalf("user signed in with role %v want the default role %d", roleID, memberRoleID)
+	}
+}
+
+func TestDeactivatedRoleIsDenied(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	role := newRole(t, seededAction(t, "ROLE_WRITE"))
+	token := login(t, newUser(t, role.ID))
+
+	ok(t, "POST", "role", token, map[string]string{"name": unique("role")}, nil)
+	ok(t, "DELETE", "active/role", adminToken, map[string]string{"roleID": fmt.Sprint(role.ID)}, nil)
+	fails(t, lib.ErrPermissionDenied, "POST", "role", token, map[string]string{"name": unique("role")})
+}
+
+func TestUpdateOwnUser(t *testing.T) {
+	user, other := newUser(t, memberRoleID), newUser(t, memberRoleID)
+	token := login(t, user)
+
+	ok(t, "PUT", "user/"+user.ID, token, map[string]string{"nickname": "me"}, nil)
+	patches(t, nil, patch.MergePatch, "user/"+user.ID, token, map[string]string{"memo": "mine"})
+	if found := findUser(t, user.ID); found.Nickname != "me" || found.Memo != "mine" {
+		t.Fatalf("own user is %q %q", found.Nickname, found.Memo)
+	}
+	fails(t, lib.ErrPermissionDenied, "PUT", "user/"+other.ID, token, map[string]string{"nickname": "them"})
+	patches(t, lib.ErrPermissionDenied, patch.MergePatch, "user/"+other.ID, token, map[string]string{"memo": "theirs"})
+}
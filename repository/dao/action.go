@@ -1,10 +1,8 @@
 package dao
 
 import (
-	"errors"
-
+	"context"
 	uuid "github.com/satori/go.uuid"
-	"gorm.io/gorm"
 )
 
 type Action struct {
@@ -19,60 +17,23 @@ type Action struct {
 	Roles       []Role          `gorm:"many2many:role_has_actions" binding:"-" json:"roles"`
 }
 
-func (m Action) Create() (Action, error) {
+func (m Action) Create(ctx context.Context) (Action, error) {
 	id := uuid.NewV4().String()
 	m.ID = id
-	if err := db.Create(&m).Error; err != nil {
+	if err := conn(ctx).Create(&m).Error; err != nil {
 		return m, err
 	}
 	return m, nil
 }
 
-func (m Action) Update(values interface{}) (Action, error) {
-	err := db.Model(&m).Updates(values).Error
+func (m Action) Update(ctx context.Context, values interface{}) (Action, error) {
+	defer FlushPermissions()
+	err := conn(ctx).Model(&m).Updates(values).Error
 	return m, err
 }
 
-func FindAction(id string, options map[string]interface{}) (Action, error) {
-	var one Action
-	if err := db.Scopes(applyQueryOptions(options)).First(&one, "id = ?", id).Error; err != nil {
-		return one, err
-	}
-	return one, nil
-}
-
-func FindActions(options map[string]interface{}) ([]Action, error) {
-	var rows []Action
-	if err := db.Scopes(applyQueryOptions(options)).Find(&rows).Error; err != nil {
-		return rows, err
-	}
-	return rows, nil
-}
-
-func FindAndCountActions(options map[string]interface{}) ([]Action, int64, error) {
-	var rows []Action
-	var count int64
-	if err := db.Scopes(applyQueryOptions(options)).Find(&rows).Error; err != nil {
-		return rows, count, err
-	}
-	delete(options, "offset")
-	delete(options, "limit")
-	delete(options, "order")
-	delete(options, "join")
-	if err := db.Model(&Action{}).Scopes(applyQueryOptions(options)).Count(&count).Error; err != nil {
-		return rows, count, err
-	}
-	return rows, count, nil
-}
-
-func ActionExists(id string) (bool, Action) {
-	var one Action
-	err := db.Where("id = ?", id).First(&one).Error
-	notFound := errors.Is(err, gorm.ErrRecordNotFound)
-	return !notFound, one
-}
-
-func (m Action) Delete() error {
+func (m Action) Delete(ctx context.Context) error {
 	// db.Model(&m).Association("Assets").Clear()
-	return db.Delete(&m).Error
+	defer FlushPermissions()
+	return conn(ctx).Delete(&m).Error
 }
@@ -1,10 +1,6 @@
 package dao
 
-import (
-	"errors"
-
-	"gorm.io/gorm"
-)
+import "context"
 
 type ActionCategory struct {
 	BaseModel
@@ -13,42 +9,19 @@ type ActionCategory struct {
 	Actions     []Action `gorm:"foreignkey:CategoryID" binding:"-" json:"actions"`
 }
 
-func (m ActionCategory) Create() (ActionCategory, error) {
-	if err := db.Create(&m).Error; err != nil {
+func (m ActionCategory) Create(ctx context.Context) (ActionCategory, error) {
+	if err := conn(ctx).Create(&m).Error; err != nil {
 		return m, err
 	}
 	return m, nil
 }
 
-func (m ActionCategory) Update(values interface{}) (ActionCategory, error) {
-	err := db.Model(&m).Updates(values).Error
+func (m ActionCategory) Update(ctx context.Context, values interface{}) (ActionCategory, error) {
+	err := conn(ctx).Model(&m).Updates(values).Error
 	return m, err
 }
 
-func FindActionCategory(id uint, options map[string]interface{}) (ActionCategory, error) {
-	var one ActionCategory
-	if err := db.Scopes(applyQueryOptions(options)).First(&one, "id = ?", id).Error; err != nil {
-		return one, err
-	}
-	return one, nil
-}
-
-func FindActionCategories(options map[string]interface{}) ([]ActionCategory, error) {
-	var rows []ActionCategory
-	if err := db.Scopes(applyQueryOptions(options)).Find(&rows).Error; err != nil {
-		return rows, err
-	}
-	return rows, nil
-}
-
-func ActionCategoryExists(id uint) (bool, ActionCategory) {
-	var one ActionCategory
-	err := db.Where("id = ?", id).First(&one).Error
-	notFound := errors.Is(err, gorm.ErrRecordNotFound)
-	return !notFound, one
-}
-
-func (m ActionCategory) Delete() error {
-	db.Model(&m).Association("Actions").Clear()
-	return db.Delete(&m).Error
+func (m ActionCategory) Delete(ctx context.Context) error {
+	conn(ctx).Model(&m).Association("Actions").Clear()
+	return conn(ctx).Delete(&m).Error
 }
@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry[V any] struct {
+	value     V
+	expiredAt time.Time
+}
+
+// ttlCache keeps lookups of hot rows in process for a short while
+type ttlCache[K comparable, V any] struct {
+	sync.RWMutex
+	ttl     time.Duration
+	entries map[K]cacheEntry[V]
+}
+
+func newTTLCache[K comparable, V any](ttl time.Duration) *ttlCache[K, V] {
+	return &ttlCache[K, V]{ttl: ttl, entries: make(map[K]cacheEntry[V])}
+}
+
+func (p *ttlCache[K, V]) get(key K) (V, bool) {
+	p.RLock()
+	defer p.RUnlock()
+	entry, ok := p.entries[key]
+	if !ok || time.Now().After(entry.expiredAt) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (p *ttlCache[K, V]) set(key K, value V) {
+	p.Lock()
+	defer p.Unlock()
+	p.entries[key] = cacheEntry[V]{value: value, expiredAt: time.Now().Add(p.ttl)}
+}
+
+func (p *ttlCache[K, V]) remove(keys ...K) {
+	p.Lock()
+	defer p.Unlock()
+	for _, key := range keys {
+		delete(p.entries, key)
+	}
+}
+
+func (p *ttlCache[K, V]) flush() {
+	p.Lock()
+	defer p.Unlock()
+	p.entries = make(map[K]cacheEntry[V])
+}
@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// IsUniqueViolation reports whether err is a unique constraint violation of any driver
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	// the sqlite driver only reports the constraint in the message
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// dateText renders column as the text of its day, or of its month when month is set
+func dateText(tx *gorm.DB, column string, month bool) string {
+	col := tx.Statement.Quote(column)
+	switch tx.Dialector.Name() {
+	case "mysql":
+		if month {
+			return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m')", col)
+		}
+		return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d')", col)
+	case "sqlite":
+		if month {
+			return fmt.Sprintf("strftime('%%Y-%%m', %s)", col)
+		}
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d', %s)", col)
+	default:
+		if month {
+			return fmt.Sprintf("to_char(%s, 'YYYY-MM')", col)
+		}
+		return fmt.Sprintf("to_char(%s, 'YYYY-MM-DD')", col)
+	}
+}
+
+// countByDate counts the rows of tx created since since, per day or per month,
+// rows are maps of createdDate and count
+func countByDate(tx *gorm.DB, since time.Time, month bool) ([]map[string]interface{}, error) {
+	all := make([]map[string]interface{}, 0)
+	date := dateText(tx, "created_at", month)
+	if !since.IsZero() {
+		tx = tx.Where("created_at >= ?", since)
+	}
+	err := tx.Select(fmt.Sprintf("%s AS %s, COUNT(*) AS count", date, tx.Statement.Quote("createdDate"))).
+		Group(date).Order(date).Scan(&all).Error
+	return all, err
+}
+
+// since returns the moment days and months ago, zero when both are 0
+func since(days uint, months uint) time.Time {
+	if days == 0 && months == 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, -int(months), -int(days))
+}
@@ -0,0 +1,16 @@
+package dao
+
+import "context"
+
+type Industry struct {
+	BaseModel
+	Name        string `gorm:"size:200;uniqueIndex;not null" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+}
+
+func (m Industry) Create(ctx context.Context) (Industry, error) {
+	if err := conn(ctx).Create(&m).Error; err != nil {
+		return m, err
+	}
+	return m, nil
+}
@@ -0,0 +1,65 @@
+package dao
+
+import (
+	"app/lib"
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+)
+
+type Message struct {
+	BaseModel
+	ID          string        `gorm:"size:100;not null;primaryKey" json:"id"`
+	SenderID    string        `gorm:"size:100;not null;index" json:"senderID"`
+	RecipientID string        `gorm:"size:100;not null;index" json:"recipientID"`
+	Content     string        `gorm:"type:text;not null" json:"content"`
+	ReadAt      lib.LocalTime `json:"readAt"`
+}
+
+func (m Message) Create(ctx context.Context) (Message, error) {
+	id := uuid.NewV4().String()
+	m.ID = id
+	if err := conn(ctx).Create(&m).Error; err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// conversation scopes messages to the ones between two users
+func conversation(ctx context.Context, a string, b string) *gorm.DB {
+	return conn(ctx).Where("(sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?)", a, b, b, a)
+}
+
+// FindConversationMessage finds the message of id when it belongs to the conversation of two users
+func FindConversationMessage(ctx context.Context, a string, b string, id string) (Message, error) {
+	var one Message
+	err := conversation(ctx, a, b).First(&one, "id = ?", id).Error
+	return one, err
+}
+
+// FindConversation returns messages between two users newest first, starting after cursor when given
+func FindConversation(ctx context.Context, a string, b string, cursor *Message, limit int) ([]Message, error) {
+	var rows []Message
+	tx := conversation(ctx, a, b)
+	if cursor != nil {
+		tx = tx.Where("(created_at < ?) OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+	err := tx.Order("created_at desc").Order("id desc").Limit(limit).Find(&rows).Error
+	return rows, err
+}
+
+// CountUnreadMessages returns the amount of unread messages of recipient grouped by sender
+func CountUnreadMessages(ctx context.Context, recipientID string) ([]map[string]interface{}, error) {
+	all := make([]map[string]interface{}, 0)
+	err := conn(ctx).Model(&Message{}).Select("sender_id, COUNT(*) AS count").
+		Where("recipient_id = ? AND read_at IS NULL", recipientID).Group("sender_id").Scan(&all).Error
+	return all, err
+}
+
+func MarkMessagesRead(ctx context.Context, recipientID string, senderID string) (int64, error) {
+	result := conn(ctx).Model(&Message{}).Where("recipient_id = ? AND sender_id = ? AND read_at IS NULL", recipientID, senderID).
+		Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
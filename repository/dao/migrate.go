@@ -0,0 +1,296 @@
+package dao
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockKey is the advisory lock held while migrating so that
+// instances starting together apply every migration once
+const migrationLockKey = 20250101
+
+// Migration is a pair of NNNN_name.up.sql and NNNN_name.down.sql files in the
+// directory of the dialect, every dialect keeps the same versions
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		file := entry.Name()
+		base, direction := strings.TrimSuffix(file, ".sql"), ""
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			base, direction = strings.TrimSuffix(base, ".up"), "up"
+		case strings.HasSuffix(base, ".down"):
+			base, direction = strings.TrimSuffix(base, ".down"), "down"
+		default:
+			return nil, fmt.Errorf("migration %s is neither up nor down", file)
+		}
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no version: %w", file, err)
+		}
+		content, err := migrationFiles.ReadFile(path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+	all := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", m.Version, m.Name)
+		}
+		all = append(all, *m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// statement is a statement of a migration file. A "-- unless column table.column"
+// line before it skips it when the column exists, mysql and sqlite cannot add a
+// column only if it is missing and databases created by AutoMigrate may lack it.
+type statement struct {
+	sql          string
+	unlessColumn string
+}
+
+// splitStatements cuts a migration file into statements ending with a
+// semicolon at the end of a line, not every driver runs several at once
+func splitStatements(sql string) []statement {
+	statements := make([]statement, 0)
+	var current strings.Builder
+	var unlessColumn string
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if column, ok := cutDirective(trimmed, "unless column"); ok {
+			unlessColumn = column
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, statement{sql: current.String(), unlessColumn: unlessColumn})
+			current.Reset()
+			unlessColumn = ""
+		}
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		statements = append(statements, statement{sql: current.String(), unlessColumn: unlessColumn})
+	}
+	return statements
+}
+
+// cutDirective returns the argument of a "-- name argument" comment
+func cutDirective(line, name string) (string, bool) {
+	if !strings.HasPrefix(line, "--") {
+		return "", false
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "--"))
+	if !strings.HasPrefix(rest, name+" ") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(rest, name)), true
+}
+
+func execStatements(tx *gorm.DB, sql string) error {
+	for _, s := range splitStatements(sql) {
+		if s.unlessColumn != "" {
+			table, column, _ := strings.Cut(s.unlessColumn, ".")
+			if tx.Migrator().HasColumn(table, column) {
+				continue
+			}
+		}
+		if err := tx.Exec(s.sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withMigrationLock runs fc on a single connection holding the advisory lock,
+// sqlite databases are opened with a single connection and need no lock
+func withMigrationLock(ctx context.Context, fc func(session *gorm.DB) error) error {
+	return conn(ctx).Connection(func(session *gorm.DB) error {
+		switch session.Dialector.Name() {
+		case "postgres":
+			if err := session.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+				return err
+			}
+			defer session.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey)
+		case "mysql":
+			name := fmt.Sprintf("schema_migrations_%d", migrationLockKey)
+			if err := session.Exec("SELECT GET_LOCK(?, -1)", name).Error; err != nil {
+				return err
+			}
+			defer session.Exec("SELECT RELEASE_LOCK(?)", name)
+		}
+		if err := session.AutoMigrate(&schemaMigration{}); err != nil {
+			return err
+		}
+		return fc(session)
+	})
+}
+
+func appliedMigrations(session *gorm.DB) (map[int64]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := session.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// MigrateUp applies at most steps pending migrations in order, all of them
+// when steps <= 0, and returns those applied
+func MigrateUp(ctx context.Context, steps int) ([]Migration, error) {
+	all, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	done := make([]Migration, 0)
+	err = withMigrationLock(ctx, func(session *gorm.DB) error {
+		applied, err := appliedMigrations(session)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			if steps > 0 && len(done) >= steps {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := session.Transaction(func(tx *gorm.DB) error {
+				if err := execStatements(tx, m.up); err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown rolls back the latest steps applied migrations, one when steps <= 0
+func MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	all, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	done := make([]Migration, 0)
+	err = withMigrationLock(ctx, func(session *gorm.DB) error {
+		applied, err := appliedMigrations(session)
+		if err != nil {
+			return err
+		}
+		for i := len(all) - 1; i >= 0 && len(done) < steps; i-- {
+			m := all[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			err := session.Transaction(func(tx *gorm.DB) error {
+				if err := execStatements(tx, m.down); err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, "version = ?", m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationStatuses lists every known migration, AppliedAt is nil while pending.
+// It only reads, a database never migrated has every migration pending.
+func MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	all, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	session := conn(ctx)
+	applied := make(map[int64]schemaMigration)
+	if session.Migrator().HasTable(&schemaMigration{}) {
+		if applied, err = appliedMigrations(session); err != nil {
+			return nil, err
+		}
+	}
+	statuses := make([]MigrationStatus, 0, len(all))
+	for _, m := range all {
+		status := MigrationStatus{Migration: m}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
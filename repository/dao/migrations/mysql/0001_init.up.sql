@@ -0,0 +1,145 @@
+-- baseline of the schema AutoMigrate used to create. IF NOT EXISTS keeps the
+-- tables of databases created by AutoMigrate, columns added to them since the
+-- first release are added by the "unless column" statements when they are missing
+
+CREATE TABLE IF NOT EXISTS roles (
+    id          bigint unsigned AUTO_INCREMENT PRIMARY KEY,
+    created_at  datetime(3),
+    updated_at  datetime(3),
+    deleted_at  datetime(3),
+    name        varchar(200) NOT NULL,
+    description text,
+    code        text,
+    is_default  boolean DEFAULT false,
+    is_actived  boolean DEFAULT true,
+    UNIQUE INDEX idx_roles_name (name),
+    INDEX idx_roles_deleted_at (deleted_at)
+);
+
+CREATE TABLE IF NOT EXISTS action_categories (
+    id          bigint unsigned AUTO_INCREMENT PRIMARY KEY,
+    created_at  datetime(3),
+    updated_at  datetime(3),
+    deleted_at  datetime(3),
+    name        varchar(100),
+    description text,
+    INDEX idx_action_categories_deleted_at (deleted_at)
+);
+
+CREATE TABLE IF NOT EXISTS actions (
+    id          varchar(100) PRIMARY KEY,
+    created_at  datetime(3),
+    updated_at  datetime(3),
+    deleted_at  datetime(3),
+    name        varchar(200) NOT NULL,
+    description text,
+    value       text,
+    category_id bigint unsigned,
+    is_actived  boolean DEFAULT false,
+    INDEX idx_actions_deleted_at (deleted_at),
+    CONSTRAINT fk_action_categories_actions FOREIGN KEY (category_id) REFERENCES action_categories (id)
+);
+
+CREATE TABLE IF NOT EXISTS role_has_actions (
+    role_id   bigint unsigned,
+    action_id varchar(100),
+    PRIMARY KEY (role_id, action_id),
+    CONSTRAINT fk_role_has_actions_role FOREIGN KEY (role_id) REFERENCES roles (id),
+    CONSTRAINT fk_role_has_actions_action FOREIGN KEY (action_id) REFERENCES actions (id)
+);
+
+-- groups is a reserved word since MySQL 8
+CREATE TABLE IF NOT EXISTS `groups` (
+    id          varchar(100) PRIMARY KEY,
+    created_at  datetime(3),
+    updated_at  datetime(3),
+    deleted_at  datetime(3),
+    name        varchar(200) NOT NULL,
+    description text,
+    size        text,
+    logo        text,
+    amount      bigint unsigned DEFAULT 0,
+    industry_id bigint unsigned,
+    owner_id    varchar(100),
+    UNIQUE INDEX idx_groups_name (name),
+    INDEX idx_groups_deleted_at (deleted_at)
+);
+-- unless column groups.industry_id
+ALTER TABLE `groups` ADD COLUMN industry_id bigint unsigned;
+
+CREATE TABLE IF NOT EXISTS users (
+    id                 varchar(100) PRIMARY KEY,
+    created_at         datetime(3),
+    updated_at         datetime(3),
+    deleted_at         datetime(3),
+    username           varchar(100) NOT NULL,
+    password           varchar(200),
+    email              varchar(200),
+    nickname           varchar(200),
+    avatar             text,
+    gender             text,
+    phone              text,
+    industry           text,
+    source             text,
+    memo               text,
+    following_amount   bigint unsigned DEFAULT 0,
+    fans_amount        bigint unsigned DEFAULT 0,
+    is_actived         boolean DEFAULT true,
+    last_logined_at    datetime(3),
+    tokens_valid_after datetime(3),
+    role_id            bigint unsigned,
+    group_id           varchar(100),
+    UNIQUE INDEX idx_users_username (username),
+    INDEX idx_username (username),
+    INDEX idx_users_deleted_at (deleted_at),
+    CONSTRAINT fk_roles_users FOREIGN KEY (role_id) REFERENCES roles (id),
+    CONSTRAINT fk_groups_users FOREIGN KEY (group_id) REFERENCES `groups` (id)
+);
+-- unless column users.tokens_valid_after
+ALTER TABLE users ADD COLUMN tokens_valid_after datetime(3);
+
+CREATE TABLE IF NOT EXISTS user_has_fans (
+    fan_id  varchar(100),
+    user_id varchar(100),
+    PRIMARY KEY (fan_id, user_id),
+    CONSTRAINT fk_user_has_fans_fans FOREIGN KEY (fan_id) REFERENCES users (id),
+    CONSTRAINT fk_user_has_fans_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          varchar(100) PRIMARY KEY,
+    created_at  datetime(3),
+    updated_at  datetime(3),
+    deleted_at  datetime(3),
+    user_id     varchar(100) NOT NULL,
+    family_id   varchar(100) NOT NULL,
+    token_hash  varchar(100) NOT NULL,
+    expired_at  datetime(3),
+    revoked_at  datetime(3),
+    replaced_by varchar(100),
+    INDEX idx_refresh_tokens_user_id (user_id),
+    INDEX idx_refresh_tokens_family_id (family_id),
+    UNIQUE INDEX idx_refresh_tokens_token_hash (token_hash),
+    INDEX idx_refresh_tokens_deleted_at (deleted_at)
+);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti        varchar(100) PRIMARY KEY,
+    expired_at datetime(3),
+    created_at datetime(3),
+    INDEX idx_revoked_tokens_expired_at (expired_at)
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id           varchar(100) PRIMARY KEY,
+    created_at   datetime(3),
+    updated_at   datetime(3),
+    deleted_at   datetime(3),
+    sender_id    varchar(100) NOT NULL,
+    recipient_id varchar(100) NOT NULL,
+    content      text NOT NULL,
+    read_at      datetime(3),
+    INDEX idx_messages_sender_id (sender_id),
+    INDEX idx_messages_recipient_id (recipient_id),
+    INDEX idx_messages_deleted_at (deleted_at)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE `groups` DROP FOREIGN KEY fk_groups_industry;
+DROP TABLE IF EXISTS industries;
@@ -0,0 +1,21 @@
+-- the first releases joined groups to an industries table they never created,
+-- it is kept when it exists
+CREATE TABLE IF NOT EXISTS industries (
+    id          bigint unsigned AUTO_INCREMENT PRIMARY KEY,
+    created_at  datetime(3),
+    updated_at  datetime(3),
+    deleted_at  datetime(3),
+    name        varchar(200) NOT NULL,
+    description text,
+    UNIQUE INDEX idx_industries_name (name),
+    INDEX idx_industries_deleted_at (deleted_at)
+);
+
+-- industries the groups point to without a row get one named after their id,
+-- so no group loses its industry to the foreign key
+INSERT INTO industries (id, created_at, updated_at, name)
+SELECT DISTINCT g.industry_id, NOW(3), NOW(3), CONCAT('industry ', g.industry_id)
+FROM `groups` g
+WHERE g.industry_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM industries i WHERE i.id = g.industry_id);
+ALTER TABLE `groups`
+    ADD CONSTRAINT fk_groups_industry FOREIGN KEY (industry_id) REFERENCES industries (id) ON DELETE SET NULL;
@@ -0,0 +1,9 @@
+-- only the actions created by the up migration are removed, seeded ones stay
+DELETE FROM role_has_actions WHERE action_id IN (
+    'e21a9d1e-c495-40fd-bed9-593ad457bbc5', 'f16bdd48-460e-479f-839c-781f02c8a763',
+    '65c12d91-d582-4e8e-ac7d-6590a2da921e', 'c9c550ff-ddec-4122-8473-7287ebf60952'
+);
+DELETE FROM actions WHERE id IN (
+    'e21a9d1e-c495-40fd-bed9-593ad457bbc5', 'f16bdd48-460e-479f-839c-781f02c8a763',
+    '65c12d91-d582-4e8e-ac7d-6590a2da921e', 'c9c550ff-ddec-4122-8473-7287ebf60952'
+);
@@ -0,0 +1,8 @@
+-- only the postgres websocket broker stores envelopes, the table keeps the
+-- versions of every driver aligned
+CREATE TABLE IF NOT EXISTS websocket_envelopes (
+    id          bigint unsigned AUTO_INCREMENT PRIMARY KEY,
+    created_at  datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    payload     longtext NOT NULL,
+    INDEX idx_websocket_envelopes_created_at (created_at)
+);
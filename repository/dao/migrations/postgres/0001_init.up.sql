@@ -0,0 +1,135 @@
+-- baseline of the schema AutoMigrate used to create. IF NOT EXISTS keeps the
+-- tables of databases created by AutoMigrate, columns added to them since the
+-- first release are added when they are missing
+
+CREATE TABLE IF NOT EXISTS roles (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    name        varchar(200) NOT NULL,
+    description text,
+    code        text,
+    is_default  boolean DEFAULT false,
+    is_actived  boolean DEFAULT true
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_name ON roles (name);
+CREATE INDEX IF NOT EXISTS idx_roles_deleted_at ON roles (deleted_at);
+
+CREATE TABLE IF NOT EXISTS action_categories (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    name        varchar(100),
+    description text
+);
+CREATE INDEX IF NOT EXISTS idx_action_categories_deleted_at ON action_categories (deleted_at);
+
+CREATE TABLE IF NOT EXISTS actions (
+    id          varchar(100) PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    name        varchar(200) NOT NULL,
+    description text,
+    value       text,
+    category_id bigint CONSTRAINT fk_action_categories_actions REFERENCES action_categories (id),
+    is_actived  boolean DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS idx_actions_deleted_at ON actions (deleted_at);
+
+CREATE TABLE IF NOT EXISTS role_has_actions (
+    role_id   bigint CONSTRAINT fk_role_has_actions_role REFERENCES roles (id),
+    action_id varchar(100) CONSTRAINT fk_role_has_actions_action REFERENCES actions (id),
+    PRIMARY KEY (role_id, action_id)
+);
+
+CREATE TABLE IF NOT EXISTS groups (
+    id          varchar(100) PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    name        varchar(200) NOT NULL,
+    description text,
+    size        text,
+    logo        text,
+    amount      bigint DEFAULT 0,
+    industry_id bigint,
+    owner_id    text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_groups_name ON groups (name);
+CREATE INDEX IF NOT EXISTS idx_groups_deleted_at ON groups (deleted_at);
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS industry_id bigint;
+
+CREATE TABLE IF NOT EXISTS users (
+    id                 varchar(100) PRIMARY KEY,
+    created_at         timestamptz,
+    updated_at         timestamptz,
+    deleted_at         timestamptz,
+    username           varchar(100) NOT NULL,
+    password           varchar(200),
+    email              varchar(200),
+    nickname           varchar(200),
+    avatar             text,
+    gender             text,
+    phone              text,
+    industry           text,
+    source             text,
+    memo               text,
+    following_amount   bigint DEFAULT 0,
+    fans_amount        bigint DEFAULT 0,
+    is_actived         boolean DEFAULT true,
+    last_logined_at    timestamptz,
+    tokens_valid_after timestamptz,
+    role_id            bigint CONSTRAINT fk_roles_users REFERENCES roles (id),
+    group_id           text CONSTRAINT fk_groups_users REFERENCES groups (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE INDEX IF NOT EXISTS idx_username ON users (username);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_valid_after timestamptz;
+
+CREATE TABLE IF NOT EXISTS user_has_fans (
+    fan_id  varchar(100) CONSTRAINT fk_user_has_fans_fans REFERENCES users (id),
+    user_id varchar(100) CONSTRAINT fk_user_has_fans_user REFERENCES users (id),
+    PRIMARY KEY (fan_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          varchar(100) PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    user_id     varchar(100) NOT NULL,
+    family_id   varchar(100) NOT NULL,
+    token_hash  varchar(100) NOT NULL,
+    expired_at  timestamptz,
+    revoked_at  timestamptz,
+    replaced_by varchar(100)
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti        varchar(100) PRIMARY KEY,
+    expired_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expired_at ON revoked_tokens (expired_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id           varchar(100) PRIMARY KEY,
+    created_at   timestamptz,
+    updated_at   timestamptz,
+    deleted_at   timestamptz,
+    sender_id    varchar(100) NOT NULL,
+    recipient_id varchar(100) NOT NULL,
+    content      text NOT NULL,
+    read_at      timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages (sender_id);
+CREATE INDEX IF NOT EXISTS idx_messages_recipient_id ON messages (recipient_id);
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages (deleted_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_groups_industry_id;
+ALTER TABLE groups DROP CONSTRAINT IF EXISTS fk_groups_industry;
+DROP TABLE IF EXISTS industries;
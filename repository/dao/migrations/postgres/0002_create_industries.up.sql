@@ -0,0 +1,23 @@
+-- the first releases joined groups to an industries table they never created,
+-- it is kept when it exists
+CREATE TABLE IF NOT EXISTS industries (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    name        varchar(200) NOT NULL,
+    description text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_industries_name ON industries (name);
+CREATE INDEX IF NOT EXISTS idx_industries_deleted_at ON industries (deleted_at);
+
+-- industries the groups point to without a row get one named after their id,
+-- so no group loses its industry to the foreign key
+INSERT INTO industries (id, created_at, updated_at, name)
+SELECT DISTINCT g.industry_id, now(), now(), 'industry ' || g.industry_id
+FROM groups g
+WHERE g.industry_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM industries i WHERE i.id = g.industry_id);
+SELECT setval(pg_get_serial_sequence('industries', 'id'), COALESCE((SELECT MAX(id) FROM industries), 0) + 1, false);
+ALTER TABLE groups
+    ADD CONSTRAINT fk_groups_industry FOREIGN KEY (industry_id) REFERENCES industries (id) ON DELETE SET NULL;
+CREATE INDEX idx_groups_industry_id ON groups (industry_id);
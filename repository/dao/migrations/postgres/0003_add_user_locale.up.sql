@@ -0,0 +1,2 @@
+-- an empty locale follows the Accept-Language header of each request
+ALTER TABLE users ADD COLUMN locale varchar(10) NOT NULL DEFAULT '';
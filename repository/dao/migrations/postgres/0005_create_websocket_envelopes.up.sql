@@ -0,0 +1,8 @@
+-- envelopes of the postgres websocket broker too large for a NOTIFY payload, the
+-- nodes are notified of their id and publishers delete them after a minute
+CREATE TABLE IF NOT EXISTS websocket_envelopes (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    payload     text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_websocket_envelopes_created_at ON websocket_envelopes (created_at);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS user_has_fans;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS role_has_actions;
+DROP TABLE IF EXISTS actions;
+DROP TABLE IF EXISTS action_categories;
+DROP TABLE IF EXISTS roles;
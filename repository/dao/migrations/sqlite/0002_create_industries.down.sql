@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_groups_industry_id;
+DROP TABLE IF EXISTS industries;
@@ -0,0 +1,21 @@
+-- the first releases joined groups to an industries table they never created,
+-- it is kept when it exists
+CREATE TABLE IF NOT EXISTS industries (
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    created_at  datetime,
+    updated_at  datetime,
+    deleted_at  datetime,
+    name        varchar(200) NOT NULL,
+    description text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_industries_name ON industries (name);
+CREATE INDEX IF NOT EXISTS idx_industries_deleted_at ON industries (deleted_at);
+
+-- industries the groups point to without a row get one named after their id,
+-- sqlite cannot add a foreign key to an existing table so groups.industry_id
+-- stays a plain column
+INSERT INTO industries (id, created_at, updated_at, name)
+SELECT DISTINCT g.industry_id, datetime('now'), datetime('now'), 'industry ' || g.industry_id
+FROM groups g
+WHERE g.industry_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM industries i WHERE i.id = g.industry_id);
+CREATE INDEX idx_groups_industry_id ON groups (industry_id);
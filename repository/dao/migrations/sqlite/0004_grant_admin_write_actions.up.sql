@@ -0,0 +1,23 @@
+-- installations seeded before the write routes were guarded have no write
+-- actions, they are created in the category of ADMIN_MENU_VISIBLE and granted to
+-- the seeded admin role so its users keep managing the platform. A database
+-- not seeded yet gets them from the seed instead.
+INSERT INTO actions (id, created_at, updated_at, name, value, category_id, is_actived)
+SELECT v.id, datetime('now'), datetime('now'), v.name, v.value, c.category_id, 1
+FROM (
+    SELECT 'e21a9d1e-c495-40fd-bed9-593ad457bbc5' AS id, '管理用户' AS name, 'USER_WRITE' AS value
+    UNION ALL SELECT 'f16bdd48-460e-479f-839c-781f02c8a763', '管理角色', 'ROLE_WRITE'
+    UNION ALL SELECT '65c12d91-d582-4e8e-ac7d-6590a2da921e', '管理权限', 'ACTION_WRITE'
+    UNION ALL SELECT 'c9c550ff-ddec-4122-8473-7287ebf60952', '管理团队', 'GROUP_WRITE'
+) v, (
+    SELECT MIN(category_id) AS category_id FROM actions WHERE value = 'ADMIN_MENU_VISIBLE' AND deleted_at IS NULL
+) c
+WHERE c.category_id IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM actions a WHERE a.value = v.value AND a.deleted_at IS NULL);
+
+INSERT INTO role_has_actions (role_id, action_id)
+SELECT r.id, a.id
+FROM roles r, actions a
+WHERE r.name = '平台管理员' AND r.deleted_at IS NULL
+    AND a.value IN ('USER_WRITE', 'ROLE_WRITE', 'ACTION_WRITE', 'GROUP_WRITE') AND a.deleted_at IS NULL
+    AND NOT EXISTS (SELECT 1 FROM role_has_actions h WHERE h.role_id = r.id AND h.action_id = a.id);
@@ -0,0 +1,8 @@
+-- only the postgres websocket broker stores envelopes, the table keeps the
+-- versions of every driver aligned
+CREATE TABLE IF NOT EXISTS websocket_envelopes (
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    created_at  datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    payload     text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_websocket_envelopes_created_at ON websocket_envelopes (created_at);
@@ -0,0 +1,38 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var permissions = newTTLCache[uint, map[string]bool](time.Minute)
+
+// FlushPermissions drops every cached role permission, call it after roles or actions changed
+func FlushPermissions() {
+	permissions.flush()
+}
+
+// RolePermissions returns the values of active actions granted to an active role
+func RolePermissions(ctx context.Context, roleID uint) (map[string]bool, error) {
+	if values, ok := permissions.get(roleID); ok {
+		return values, nil
+	}
+	role, err := Roles.Find(ctx, roleID, NewQuery().Preload("Actions", func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("is_actived = ?", true)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]bool)
+	if role.IsActived {
+		for _, action := range role.Actions {
+			if action.Value != "" {
+				values[action.Value] = true
+			}
+		}
+	}
+	permissions.set(roleID, values)
+	return values, nil
+}
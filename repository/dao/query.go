@@ -0,0 +1,108 @@
+package dao
+
+import "gorm.io/gorm"
+
+type clauseArgs struct {
+	query interface{}
+	args  []interface{}
+}
+
+// Query describes how Find* functions load rows, a nil *Query loads everything
+type Query struct {
+	preloads []clauseArgs
+	selects  []string
+	wheres   []clauseArgs
+	joins    []clauseArgs
+	orders   []string
+	offset   int
+	limit    int
+}
+
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Preload loads the association name, args are conditions or a func(*gorm.DB) *gorm.DB
+func (q *Query) Preload(name string, args ...interface{}) *Query {
+	q.preloads = append(q.preloads, clauseArgs{query: name, args: args})
+	return q
+}
+
+func (q *Query) Select(cols ...string) *Query {
+	q.selects = append(q.selects, cols...)
+	return q
+}
+
+func (q *Query) Where(query interface{}, args ...interface{}) *Query {
+	q.wheres = append(q.wheres, clauseArgs{query: query, args: args})
+	return q
+}
+
+func (q *Query) WhereIDs(ids interface{}) *Query {
+	return q.Where("id IN (?)", ids)
+}
+
+func (q *Query) Join(query string, args ...interface{}) *Query {
+	q.joins = append(q.joins, clauseArgs{query: query, args: args})
+	return q
+}
+
+func (q *Query) Order(orders ...string) *Query {
+	q.orders = append(q.orders, orders...)
+	return q
+}
+
+// Page limits rows to the page starting from 1 of the given size
+func (q *Query) Page(page int, size int) *Query {
+	if page < 1 {
+		page = 1
+	}
+	q.offset = (page - 1) * size
+	q.limit = size
+	return q
+}
+
+func (q *Query) scope() func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if q == nil {
+			return tx
+		}
+		for _, p := range q.preloads {
+			tx = tx.Preload(p.query.(string), p.args...)
+		}
+		if len(q.selects) > 0 {
+			tx = tx.Select(q.selects)
+		}
+		tx = q.filter(tx)
+		for _, order := range q.orders {
+			tx = tx.Order(order)
+		}
+		if q.offset > 0 {
+			tx = tx.Offset(q.offset)
+		}
+		if q.limit > 0 {
+			tx = tx.Limit(q.limit)
+		}
+		return tx
+	}
+}
+
+// countScope keeps only what narrows the rows down
+func (q *Query) countScope() func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if q == nil {
+			return tx
+		}
+		return q.filter(tx)
+	}
+}
+
+func (q *Query) filter(tx *gorm.DB) *gorm.DB {
+	for _, j := range q.joins {
+		tx = tx.Joins(j.query.(string), j.args...)
+	}
+	for _, w := range q.wheres {
+		tx = tx.Where(w.query, w.args...)
+	}
+	return tx
+}
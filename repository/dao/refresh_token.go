@@ -0,0 +1,71 @@
+package dao
+
+import (
+	"app/lib"
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+type RefreshToken struct {
+	BaseModel
+	ID         string        `gorm:"size:100;not null;primaryKey" json:"id"`
+	UserID     string        `gorm:"size:100;not null;index" json:"userID"`
+	FamilyID   string        `gorm:"size:100;not null;index" json:"familyID"`
+	TokenHash  string        `gorm:"size:100;not null;uniqueIndex" json:"-"`
+	ExpiredAt  lib.LocalTime `json:"expiredAt"`
+	RevokedAt  lib.LocalTime `json:"revokedAt"`
+	ReplacedBy string        `gorm:"size:100" json:"replacedBy"`
+}
+
+func (m RefreshToken) Create(ctx context.Context) (RefreshToken, error) {
+	m.ID = uuid.NewV4().String()
+	if m.FamilyID == "" {
+		m.FamilyID = m.ID
+	}
+	if err := conn(ctx).Create(&m).Error; err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+func (m RefreshToken) IsRevoked() bool {
+	return !m.RevokedAt.IsZero()
+}
+
+func (m RefreshToken) IsExpired() bool {
+	return time.Now().After(m.ExpiredAt.Time)
+}
+
+// Rotate revokes the token and stores its successor of the same family in one transaction
+func (m RefreshToken) Rotate(ctx context.Context, next RefreshToken) (RefreshToken, error) {
+	next.ID = uuid.NewV4().String()
+	next.FamilyID = m.FamilyID
+	next.UserID = m.UserID
+	err := Transaction(ctx, func(ctx context.Context) error {
+		tx := conn(ctx)
+		// the revoked_at guard makes concurrent rotations of the same token fail
+		result := tx.Model(&RefreshToken{}).Where("id = ? AND revoked_at IS NULL", m.ID).Updates(map[string]interface{}{
+			"revoked_at": time.Now(), "replaced_by": next.ID,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return lib.ErrRefreshTokenInvalid
+		}
+		return tx.Create(&next).Error
+	})
+	return next, err
+}
+
+func FindRefreshTokenByHash(ctx context.Context, hash string) (RefreshToken, error) {
+	var one RefreshToken
+	err := conn(ctx).Where("token_hash = ?", hash).First(&one).Error
+	return one, err
+}
+
+func RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return conn(ctx).Model(&RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", familyID).Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"app/lib"
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Repository implements the operations every model shares, models with extra
+// behaviour keep it as their own methods or functions next to the model
+type Repository[T any] struct{}
+
+var (
+	Users            = Repository[User]{}
+	Roles            = Repository[Role]{}
+	Actions          = Repository[Action]{}
+	ActionCategories = Repository[ActionCategory]{}
+	Groups           = Repository[Group]{}
+	Messages         = Repository[Message]{}
+	Industries       = Repository[Industry]{}
+)
+
+func (Repository[T]) Find(ctx context.Context, id interface{}, q *Query) (T, error) {
+	var one T
+	if err := conn(ctx).Scopes(q.scope()).First(&one, "id = ?", id).Error; err != nil {
+		return one, err
+	}
+	return one, nil
+}
+
+func (Repository[T]) FindAll(ctx context.Context, q *Query) ([]T, error) {
+	var rows []T
+	if err := conn(ctx).Scopes(q.scope()).Find(&rows).Error; err != nil {
+		return rows, err
+	}
+	return rows, nil
+}
+
+func (Repository[T]) FindAndCount(ctx context.Context, q *Query) ([]T, int64, error) {
+	var rows []T
+	var count int64
+	if err := conn(ctx).Scopes(q.scope()).Find(&rows).Error; err != nil {
+		return rows, count, err
+	}
+	if err := conn(ctx).Model(new(T)).Scopes(q.countScope()).Count(&count).Error; err != nil {
+		return rows, count, err
+	}
+	return rows, count, nil
+}
+
+func (r Repository[T]) Exists(ctx context.Context, id interface{}) (bool, T, error) {
+	return r.ExistsBy(ctx, "id", id)
+}
+
+// ExistsBy looks a row up by the value of column field, a missing row is not
+// an error while failed queries like timeouts are
+func (Repository[T]) ExistsBy(ctx context.Context, field string, value interface{}) (bool, T, error) {
+	var one T
+	err := conn(ctx).Where(map[string]interface{}{field: value}).First(&one).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, one, nil
+	}
+	if err != nil {
+		return false, one, err
+	}
+	return true, one, nil
+}
+
+// NotFoundAs replaces the error of a missing row by missing, other errors are kept
+func NotFoundAs(err error, missing *lib.AppError) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return missing
+	}
+	return err
+}
+
+// CreateInBatches inserts rows size by size, hooks of the model still run
+func (Repository[T]) CreateInBatches(ctx context.Context, rows []T, size int) ([]T, error) {
+	err := conn(ctx).CreateInBatches(&rows, size).Error
+	return rows, err
+}
+
+func (Repository[T]) UpdateAll(ctx context.Context, values interface{}, ids interface{}) error {
+	return conn(ctx).Model(new(T)).Where("id IN (?)", ids).Updates(values).Error
+}
+
+// Delete soft deletes the row of id and returns it
+func (Repository[T]) Delete(ctx context.Context, id interface{}) (T, error) {
+	var one T
+	if err := conn(ctx).First(&one, "id = ?", id).Error; err != nil {
+		return one, err
+	}
+	err := conn(ctx).Delete(&one).Error
+	return one, err
+}
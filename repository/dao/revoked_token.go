@@ -0,0 +1,32 @@
+package dao
+
+import (
+	"app/lib"
+	"context"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+type RevokedToken struct {
+	JTI       string        `gorm:"column:jti;size:100;not null;primaryKey" json:"jti"`
+	ExpiredAt lib.LocalTime `gorm:"index" json:"expiredAt"`
+	CreatedAt lib.LocalTime `json:"createdAt"`
+}
+
+// RevocationStore keeps revoked token ids in the database so every instance shares them
+type RevocationStore struct{}
+
+func (RevocationStore) Revoke(ctx context.Context, jti string, expiredAt time.Time) error {
+	if err := conn(ctx).Where("expired_at < ?", time.Now()).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+	m := RevokedToken{JTI: jti, ExpiredAt: lib.LocalTime{Time: expiredAt}}
+	return conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&m).Error
+}
+
+func (RevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := conn(ctx).Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
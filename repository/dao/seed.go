@@ -0,0 +1,100 @@
+package dao
+
+import (
+	"context"
+	"errors"
+)
+
+// AdminRoleName is the role Seed grants every admin action to
+const AdminRoleName = "平台管理员"
+
+var ErrAlreadySeeded = errors.New("database is already seeded")
+
+// Seed creates the default action category, actions and roles of a new
+// installation, roles are created in order so the admin, group admin and
+// member roles get ids 1, 2 and 3 that config refers to.
+// Users are not seeded, create the first admin with CreateAdmin.
+func Seed(ctx context.Context) error {
+	var count int64
+	if err := conn(ctx).Model(&Role{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAlreadySeeded
+	}
+	newActionCategory := ActionCategory{
+		Name: "基础权限",
+	}
+	actionCategory, err := newActionCategory.Create(ctx)
+	if err != nil {
+		return err
+	}
+	actions := []Action{
+		{Name: "管理菜单可见", Value: "ADMIN_MENU_VISIBLE", IsActived: true, CategoryID: actionCategory.ID},
+	}
+	next := make([]Action, 0)
+	for _, v := range actions {
+		created, err := v.Create(ctx)
+		if err != nil {
+			return err
+		}
+		next = append(next, created)
+	}
+	adminActions := []Action{
+		{Name: "管理用户", Value: "USER_WRITE", IsActived: true, CategoryID: actionCategory.ID},
+		{Name: "管理角色", Value: "ROLE_WRITE", IsActived: true, CategoryID: actionCategory.ID},
+		{Name: "管理权限", Value: "ACTION_WRITE", IsActived: true, CategoryID: actionCategory.ID},
+		{Name: "管理团队", Value: "GROUP_WRITE", IsActived: true, CategoryID: actionCategory.ID},
+	}
+	granted := append([]Action{}, next...)
+	for _, v := range adminActions {
+		created, err := v.Create(ctx)
+		if err != nil {
+			return err
+		}
+		granted = append(granted, created)
+	}
+	role := Role{
+		Name: AdminRoleName, IsDefault: true, IsActived: true,
+	}
+	if _, err := role.Create(ctx, granted); err != nil {
+		return err
+	}
+	roles := []Role{
+		{Name: "团队管理员", IsDefault: true, IsActived: true},
+		{Name: "普通成员", IsDefault: true, IsActived: true},
+	}
+	for _, v := range roles {
+		_, err := v.Create(ctx, next)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateAdmin creates a user with the role of roleID, the seeded admin role when roleID is 0
+func CreateAdmin(ctx context.Context, user User, roleID uint) (User, error) {
+	if roleID == 0 {
+		exists, role, err := Roles.ExistsBy(ctx, "name", AdminRoleName)
+		if err != nil {
+			return user, err
+		}
+		if !exists {
+			return user, errors.New("admin role not found, run seed first")
+		}
+		roleID = role.ID
+	} else if exists, _, err := Roles.Exists(ctx, roleID); err != nil {
+		return user, err
+	} else if !exists {
+		return user, errors.New("role not found")
+	}
+	if exists, _, err := Users.ExistsBy(ctx, "username", user.Username); err != nil {
+		return user, err
+	} else if exists {
+		return user, errors.New("username already exists")
+	}
+	user.RoleID = &roleID
+	user.IsActived = true
+	return user.Create(ctx)
+}
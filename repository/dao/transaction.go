@@ -0,0 +1,18 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// Transaction runs fc in a database transaction, committed when fc returns nil.
+// The ctx handed to fc carries the transaction, every dao function called with
+// it joins the transaction, and calling Transaction again with it opens a savepoint.
+func Transaction(ctx context.Context, fc func(ctx context.Context) error) error {
+	return conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fc(context.WithValue(ctx, txKey{}, tx))
+	})
+}
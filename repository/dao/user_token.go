@@ -0,0 +1,43 @@
+package dao
+
+import (
+	"context"
+	"time"
+)
+
+// tokensValidAfter caches the moment per node, InvalidateUserTokens clears it on
+// the node handling the request while other nodes keep accepting the revoked
+// access tokens until their entry expires
+var tokensValidAfter = newTTLCache[string, time.Time](30 * time.Second)
+
+// TokensValidAfter returns the moment up to which tokens of the user are rejected
+func TokensValidAfter(ctx context.Context, id string) (time.Time, error) {
+	if validAfter, ok := tokensValidAfter.get(id); ok {
+		return validAfter, nil
+	}
+	var one User
+	if err := conn(ctx).Select("id", "tokens_valid_after").First(&one, "id = ?", id).Error; err != nil {
+		return time.Time{}, err
+	}
+	tokensValidAfter.set(id, one.TokensValidAfter.Time)
+	return one.TokensValidAfter.Time, nil
+}
+
+// InvalidateUserTokens rejects every token issued to the users so far and revokes their refresh tokens
+func InvalidateUserTokens(ctx context.Context, ids []string) error {
+	// every driver stores milliseconds
+	now := time.Now().Truncate(time.Millisecond)
+	err := Transaction(ctx, func(ctx context.Context) error {
+		tx := conn(ctx)
+		err := tx.Model(&User{}).Where("id IN (?)", ids).Update("tokens_valid_after", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&RefreshToken{}).Where("user_id IN (?) AND revoked_at IS NULL", ids).Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+	tokensValidAfter.remove(ids...)
+	return nil
+}
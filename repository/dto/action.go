@@ -1,10 +1,12 @@
 package dto
 
 import (
+	"app/lib"
+	"app/lib/patch"
 	"app/repository/dao"
+	"context"
 	"errors"
 	"fmt"
-	"strings"
 
 	"gorm.io/gorm"
 )
@@ -16,15 +18,18 @@ type NewAction struct {
 	CategoryID  uint   `binding:"required,numeric,gt=0" json:"categoryID"`
 }
 
-func (body *NewAction) Create() (dao.Action, error) {
+func (body *NewAction) Create(ctx context.Context) (dao.Action, error) {
 	m := dao.Action{
 		Name: body.Name, Description: body.Description, Value: body.Value, CategoryID: body.CategoryID,
 	}
-	exists, _ := dao.ActionCategoryExists(body.CategoryID)
+	exists, _, err := dao.ActionCategories.Exists(ctx, body.CategoryID)
+	if err != nil {
+		return m, err
+	}
 	if !exists {
-		return m, errors.New("权限分类不存在")
+		return m, lib.ErrCategoryNotFound
 	}
-	return m.Create()
+	return m.Create(ctx)
 }
 
 type UpdateAction struct {
@@ -35,19 +40,22 @@ type UpdateAction struct {
 	IsActived   bool   `binding:"omitempty" json:"isActived"`
 }
 
-func (body *UpdateAction) Save(id string) (dao.Action, error) {
-	m, err := dao.FindAction(id, nil)
+func (body *UpdateAction) Save(ctx context.Context, id string) (dao.Action, error) {
+	m, err := dao.Actions.Find(ctx, id, nil)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return m, errors.New("权限不存在")
+			return m, lib.ErrActionNotFound
 		} else {
 			return m, err
 		}
 	}
 	if body.CategoryID != nil {
-		exists, _ := dao.ActionCategoryExists(*body.CategoryID)
+		exists, _, err := dao.ActionCategories.Exists(ctx, *body.CategoryID)
+		if err != nil {
+			return m, err
+		}
 		if !exists {
-			return m, errors.New("权限分类不存在")
+			return m, lib.ErrCategoryNotFound
 		}
 	}
 	values := map[string]interface{}{
@@ -58,7 +66,44 @@ func (body *UpdateAction) Save(id string) (dao.Action, error) {
 		"is_actived":  body.IsActived,
 	}
 	values = omitEmpty(values)
-	return m.Update(values)
+	return m.Update(ctx, values)
+}
+
+// PatchAction holds the fields of an action a patch may change
+type PatchAction struct {
+	Name        string `binding:"omitempty,lt=200" json:"name"`
+	Description string `json:"description"`
+	Value       string `json:"value"`
+	CategoryID  uint   `binding:"required,gt=0" json:"categoryID"`
+	IsActived   bool   `json:"isActived"`
+}
+
+// Patch applies p to the action of id, a changed category must exist
+func (body *PatchAction) Patch(ctx context.Context, id string, p patch.Patch) (dao.Action, error) {
+	m, err := dao.Actions.Find(ctx, id, nil)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return m, lib.ErrActionNotFound
+		}
+		return m, err
+	}
+	*body = PatchAction{
+		Name: m.Name, Description: m.Description, Value: m.Value, CategoryID: m.CategoryID, IsActived: m.IsActived,
+	}
+	values, err := p.Apply(body)
+	if err != nil || len(values) == 0 {
+		return m, err
+	}
+	if _, changed := values["CategoryID"]; changed {
+		exists, _, err := dao.ActionCategories.Exists(ctx, body.CategoryID)
+		if err != nil {
+			return m, err
+		}
+		if !exists {
+			return m, lib.ErrCategoryNotFound
+		}
+	}
+	return m.Update(ctx, values)
 }
 
 type QueryAction struct {
@@ -69,18 +114,12 @@ type QueryAction struct {
 	SortOrder string `form:"sortOrder,default=desc" binding:"oneof=asc desc" json:"sortOrder"`
 }
 
-func (query *QueryAction) Find() ([]dao.Action, int64, error) {
-	where := make([][]interface{}, 0)
+func (query *QueryAction) Find(ctx context.Context) ([]dao.Action, int64, error) {
+	q := dao.NewQuery().Preload("Category")
 	if query.Key != "" {
-		where = append(where, []interface{}{"name LIKE ?", fmt.Sprintf("%%%s%%", query.Key)})
+		q.Where("name LIKE ?", fmt.Sprintf("%%%s%%", query.Key))
 	}
-	return dao.FindAndCountActions(map[string]interface{}{
-		"where":   where,
-		"preload": []string{"Category"},
-		"offset":  (query.Page - 1) * query.Limit,
-		"limit":   query.Limit,
-		"order":   fmt.Sprintf("%s %s", query.SortBy, query.SortOrder),
-	})
+	return dao.Actions.FindAndCount(ctx, q.Order(fmt.Sprintf("%s %s", query.SortBy, query.SortOrder)).Page(query.Page, query.Limit))
 }
 
 func isActionExist(action dao.Action, actions []dao.Action) bool {
@@ -97,64 +136,61 @@ type OPAction struct {
 	ActionID string `uri:"actionID" json:"actionID"`
 }
 
-func (body OPAction) Grant() (err error) {
-	role, err := dao.FindRole(body.RoleID, map[string]interface{}{
-		"preload": []string{"Actions"},
-	})
-	if err != nil {
-		return err
-	}
-	actions, err := dao.FindActions(map[string]interface{}{
-		"where": strings.Split(body.ActionID, ","),
-	})
-	if err != nil {
-		return err
-	}
-	var next []dao.Action
-	for _, action := range actions {
-		if !isActionExist(action, role.Actions) {
-			next = append(next, action)
+func (body OPAction) Grant(ctx context.Context) (err error) {
+	defer dao.FlushPermissions()
+	return dao.Transaction(ctx, func(ctx context.Context) error {
+		role, err := dao.Roles.Find(ctx, body.RoleID, dao.NewQuery().Preload("Actions"))
+		if err != nil {
+			return dao.NotFoundAs(err, lib.ErrRoleNotFound)
 		}
-	}
-	return role.Relations("Actions").Append(next)
+		actions, err := findIDs(ctx, dao.Actions, body.ActionID, actionID, lib.ErrActionNotFound)
+		if err != nil {
+			return err
+		}
+		var next []dao.Action
+		for _, action := range actions {
+			if !isActionExist(action, role.Actions) {
+				next = append(next, action)
+			}
+		}
+		return role.Relations(ctx, "Actions").Append(next)
+	})
 }
 
-func (body OPAction) Revoke() (err error) {
-	role, err := dao.FindRole(body.RoleID, map[string]interface{}{
-		"preload": []string{"Actions"},
-	})
-	if err != nil {
-		return err
-	}
-	actions, err := dao.FindActions(map[string]interface{}{
-		"where": strings.Split(body.ActionID, ","),
-	})
-	if err != nil {
-		return err
-	}
-	var next []dao.Action
-	for _, action := range actions {
-		if isActionExist(action, role.Actions) {
-			next = append(next, action)
+func (body OPAction) Revoke(ctx context.Context) (err error) {
+	defer dao.FlushPermissions()
+	return dao.Transaction(ctx, func(ctx context.Context) error {
+		role, err := dao.Roles.Find(ctx, body.RoleID, dao.NewQuery().Preload("Actions"))
+		if err != nil {
+			return dao.NotFoundAs(err, lib.ErrRoleNotFound)
 		}
-	}
-	return role.Relations("Actions").Delete(next)
+		actions, err := findIDs(ctx, dao.Actions, body.ActionID, actionID, lib.ErrActionNotFound)
+		if err != nil {
+			return err
+		}
+		var next []dao.Action
+		for _, action := range actions {
+			if isActionExist(action, role.Actions) {
+				next = append(next, action)
+			}
+		}
+		return role.Relations(ctx, "Actions").Delete(next)
+	})
 }
 
-func (body OPAction) Change() (err error) {
-	role, err := dao.FindRole(body.RoleID, map[string]interface{}{
-		"preload": []string{"Actions"},
-	})
-	if err != nil {
-		return err
-	}
-	actions, err := dao.FindActions(map[string]interface{}{
-		"where": strings.Split(body.ActionID, ","),
+func (body OPAction) Change(ctx context.Context) (err error) {
+	defer dao.FlushPermissions()
+	return dao.Transaction(ctx, func(ctx context.Context) error {
+		role, err := dao.Roles.Find(ctx, body.RoleID, dao.NewQuery().Preload("Actions"))
+		if err != nil {
+			return dao.NotFoundAs(err, lib.ErrRoleNotFound)
+		}
+		actions, err := findIDs(ctx, dao.Actions, body.ActionID, actionID, lib.ErrActionNotFound)
+		if err != nil {
+			return err
+		}
+		var next []dao.Action
+		next = append(next, actions...)
+		return role.Relations(ctx, "Actions").Replace(next)
 	})
-	if err != nil {
-		return err
-	}
-	var next []dao.Action
-	next = append(next, actions...)
-	return role.Relations("Actions").Replace(next)
 }
@@ -1,7 +1,10 @@
 package dto
 
 import (
+	"app/lib"
+	"app/lib/patch"
 	"app/repository/dao"
+	"context"
 	"errors"
 
 	"gorm.io/gorm"
@@ -12,11 +15,11 @@ type NewActionCategory struct {
 	Description string `json:"description"`
 }
 
-func (body *NewActionCategory) Create() (dao.ActionCategory, error) {
+func (body *NewActionCategory) Create(ctx context.Context) (dao.ActionCategory, error) {
 	m := dao.ActionCategory{
 		Name: body.Name, Description: body.Description,
 	}
-	return m.Create()
+	return m.Create(ctx)
 }
 
 type UpdateActionCategory struct {
@@ -24,11 +27,11 @@ type UpdateActionCategory struct {
 	Description string `json:"description"`
 }
 
-func (body *UpdateActionCategory) Save(id uint) (dao.ActionCategory, error) {
-	m, err := dao.FindActionCategory(id, nil)
+func (body *UpdateActionCategory) Save(ctx context.Context, id uint) (dao.ActionCategory, error) {
+	m, err := dao.ActionCategories.Find(ctx, id, nil)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return m, errors.New("权限分类不存在")
+			return m, lib.ErrCategoryNotFound
 		} else {
 			return m, err
 		}
@@ -38,5 +41,28 @@ func (body *UpdateActionCategory) Save(id uint) (dao.ActionCategory, error) {
 		"description": body.Description,
 	}
 	values = omitEmpty(values)
-	return m.Update(values)
+	return m.Update(ctx, values)
+}
+
+// PatchActionCategory holds the fields of an action category a patch may change
+type PatchActionCategory struct {
+	Name        string `binding:"omitempty,lt=200" json:"name"`
+	Description string `json:"description"`
+}
+
+// Patch applies p to the action category of id
+func (body *PatchActionCategory) Patch(ctx context.Context, id uint, p patch.Patch) (dao.ActionCategory, error) {
+	m, err := dao.ActionCategories.Find(ctx, id, nil)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return m, lib.ErrCategoryNotFound
+		}
+		return m, err
+	}
+	*body = PatchActionCategory{Name: m.Name, Description: m.Description}
+	values, err := p.Apply(body)
+	if err != nil || len(values) == 0 {
+		return m, err
+	}
+	return m.Update(ctx, values)
 }
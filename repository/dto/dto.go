@@ -1,6 +1,13 @@
 package dto
 
-import "reflect"
+import (
+	"app/lib"
+	"app/repository/dao"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
 
 func omitEmpty(values map[string]interface{}) map[string]interface{} {
 	for k, v := range values {
@@ -15,3 +22,39 @@ func omitEmpty(values map[string]interface{}) map[string]interface{} {
 	}
 	return values
 }
+
+// findIDs finds the rows of comma separated ids, ids without a row fail with
+// notFound listing them in its details
+func findIDs[T any](ctx context.Context, repo dao.Repository[T], ids string, idOf func(T) string, notFound *lib.AppError) ([]T, error) {
+	var list []string
+	for _, id := range strings.Split(ids, ",") {
+		if id != "" {
+			list = append(list, id)
+		}
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	rows, err := repo.FindAll(ctx, dao.NewQuery().WhereIDs(list))
+	if err != nil {
+		return rows, err
+	}
+	found := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		found[idOf(row)] = true
+	}
+	var missing []string
+	for _, id := range list {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return rows, notFound.WithDetails(missing)
+	}
+	return rows, nil
+}
+
+func userID(m dao.User) string     { return m.ID }
+func roleID(m dao.Role) string     { return fmt.Sprint(m.ID) }
+func actionID(m dao.Action) string { return m.ID }
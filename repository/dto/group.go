@@ -1,7 +1,10 @@
 package dto
 
 import (
+	"app/lib"
+	"app/lib/patch"
 	"app/repository/dao"
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -17,11 +20,14 @@ type NewGroup struct {
 	IndustryID  uint   `binding:"omitempty,numeric,gt=0" json:"industryID"`
 }
 
-func (body *NewGroup) Create(user *dao.User, role *dao.Role) (dao.Group, error) {
+func (body *NewGroup) Create(ctx context.Context, user *dao.User, role *dao.Role) (dao.Group, error) {
 	m := dao.Group{
 		Name: body.Name, Description: body.Description, Size: body.Size, Logo: body.Logo,
 	}
-	return m.Create(user, role)
+	if body.IndustryID > 0 {
+		m.IndustryID = &body.IndustryID
+	}
+	return m.Create(ctx, user, role)
 }
 
 type UpdateGroup struct {
@@ -32,11 +38,11 @@ type UpdateGroup struct {
 	IndustryID  uint   `binding:"omitempty,numeric,gt=0" json:"industryID"`
 }
 
-func (body *UpdateGroup) Save(id string) (dao.Group, error) {
-	m, err := dao.FindGroup(id, nil)
+func (body *UpdateGroup) Save(ctx context.Context, id string) (dao.Group, error) {
+	m, err := dao.Groups.Find(ctx, id, nil)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return m, errors.New("团队不存在")
+			return m, lib.ErrGroupNotFound
 		} else {
 			return m, err
 		}
@@ -46,40 +52,58 @@ func (body *UpdateGroup) Save(id string) (dao.Group, error) {
 		"description": body.Description,
 		"size":        body.Size,
 		"logo":        body.Logo,
-		"industry_id": body.IndustryID,
+	}
+	if body.IndustryID > 0 {
+		values["industry_id"] = body.IndustryID
 	}
 	values = omitEmpty(values)
-	return m.Update(values)
+	return m.Update(ctx, values)
+}
+
+// PatchGroup holds the fields of a group a patch may change, a null industryID
+// clears the industry
+type PatchGroup struct {
+	Name        string `binding:"omitempty,lt=200" json:"name"`
+	Description string `json:"description"`
+	Size        string `json:"size"`
+	Logo        string `json:"logo"`
+	IndustryID  *uint  `binding:"omitempty,gt=0" json:"industryID"`
+}
+
+// Patch applies p to group
+func (body *PatchGroup) Patch(ctx context.Context, group dao.Group, p patch.Patch) (dao.Group, error) {
+	*body = PatchGroup{
+		Name: group.Name, Description: group.Description, Size: group.Size, Logo: group.Logo, IndustryID: group.IndustryID,
+	}
+	values, err := p.Apply(body)
+	if err != nil || len(values) == 0 {
+		return group, err
+	}
+	return group.Update(ctx, values)
 }
 
 type QueryGroup struct {
 	Key       string `form:"key" binding:"max=10"`
 	Page      int    `form:"page,default=1" binding:"min=1" json:"page"`
 	Limit     int    `form:"limit,default=10" binding:"min=1" json:"limit"`
-	SortBy    string `form:"sortBy,default=created_at" binding:"oneof=created_at updated_at last_logined_at" json:"sortBy"`
+	SortBy    string `form:"sortBy,default=created_at" binding:"oneof=created_at updated_at amount" json:"sortBy"`
 	SortOrder string `form:"sortOrder,default=desc" binding:"oneof=asc desc" json:"sortOrder"`
 }
 
-func (query *QueryGroup) Find() ([]dao.Group, int64, error) {
-	where := make([][]interface{}, 0)
+func (query *QueryGroup) Find(ctx context.Context) ([]dao.Group, int64, error) {
+	q := dao.NewQuery()
 	if query.Key != "" {
-		where = append(where, []interface{}{"name LIKE ?", fmt.Sprintf("%%%s%%", query.Key)})
+		q.Where("name LIKE ?", fmt.Sprintf("%%%s%%", query.Key))
 	}
-	return dao.FindAndCountGroups(map[string]interface{}{
-		"where": where,
-		// "preload": []string{"Role", "AssetFolder"},
-		"offset": (query.Page - 1) * query.Limit,
-		"limit":  query.Limit,
-		"order":  fmt.Sprintf("%s %s", query.SortBy, query.SortOrder),
-	})
+	return dao.FindAndCountGroups(ctx, q.Order(fmt.Sprintf("%s %s", query.SortBy, query.SortOrder)).Page(query.Page, query.Limit))
 }
 
 type DeleteGroup struct {
-	ID string `binding:"omitempty" json:"id"`
+	ID string `binding:"required" json:"id"`
 }
 
-func (body *DeleteGroup) Delete(defaultRole uint) (err error) {
-	return dao.DeleteGroup(strings.Split(body.ID, ","), defaultRole)
+func (body *DeleteGroup) Delete(ctx context.Context, defaultRole uint) (err error) {
+	return dao.DeleteGroup(ctx, strings.Split(body.ID, ","), defaultRole)
 }
 
 type IOGroup struct {
@@ -87,35 +111,37 @@ type IOGroup struct {
 	UserID  string `binding:"required" json:"userID"`
 }
 
-func (body *IOGroup) In() (dao.Group, error) {
-	group, err := dao.FindGroup(body.GroupID, nil)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return group, errors.New("团队不存在")
-		} else {
-			return group, err
+func (body *IOGroup) In(ctx context.Context) (dao.Group, error) {
+	var group dao.Group
+	err := dao.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		group, err = dao.Groups.Find(ctx, body.GroupID, nil)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return lib.ErrGroupNotFound
+			} else {
+				return err
+			}
 		}
-	}
-	err = group.AddUsers(strings.Split(body.UserID, ","))
-	if err != nil {
-		return group, err
-	}
-	return group, nil
+		return group.AddUsers(ctx, strings.Split(body.UserID, ","))
+	})
+	return group, err
 }
 
-func (body *IOGroup) Out(defaultRoleID uint) (dao.Group, error) {
-	group, err := dao.FindGroup(body.GroupID, nil)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return group, errors.New("团队不存在")
-		} else {
-			return group, err
+func (body *IOGroup) Out(ctx context.Context, defaultRoleID uint) (dao.Group, error) {
+	var group dao.Group
+	err := dao.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		group, err = dao.Groups.Find(ctx, body.GroupID, nil)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return lib.ErrGroupNotFound
+			} else {
+				return err
+			}
 		}
-	}
-	userIDs := strings.Split(body.UserID, ",")
-	err = group.RemoveUsers(userIDs, defaultRoleID)
-	if err != nil {
-		return group, err
-	}
-	return group, nil
+		userIDs := strings.Split(body.UserID, ",")
+		return group.RemoveUsers(ctx, userIDs, defaultRoleID)
+	})
+	return group, err
 }
@@ -0,0 +1,72 @@
+package dto
+
+import (
+	"app/lib"
+	"app/repository/dao"
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type NewMessage struct {
+	To      string `binding:"required" json:"to"`
+	Content string `binding:"required,max=2000" json:"content"`
+}
+
+func (body *NewMessage) Send(ctx context.Context, from string) (dao.Message, error) {
+	m := dao.Message{
+		SenderID: from, RecipientID: body.To, Content: body.Content,
+	}
+	if body.To == from {
+		return m, lib.ErrMessageToSelf
+	}
+	exists, _, err := dao.Users.Exists(ctx, body.To)
+	if err != nil {
+		return m, err
+	}
+	if !exists {
+		return m, lib.ErrUserNotFound
+	}
+	return m.Create(ctx)
+}
+
+type QueryConversation struct {
+	Cursor string `form:"cursor" json:"cursor"`
+	Limit  int    `form:"limit,default=20" binding:"min=1,max=100" json:"limit"`
+}
+
+// Find returns a page of the conversation of me and other and the cursor of the next page
+func (query *QueryConversation) Find(ctx context.Context, me string, other string) ([]dao.Message, string, error) {
+	var cursor *dao.Message
+	if query.Cursor != "" {
+		// a cursor of another conversation is not found in this one
+		found, err := dao.FindConversationMessage(ctx, me, other, query.Cursor)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, "", lib.ErrMessageNotFound
+			} else {
+				return nil, "", err
+			}
+		}
+		cursor = &found
+	}
+	rows, err := dao.FindConversation(ctx, me, other, cursor, query.Limit)
+	if err != nil {
+		return rows, "", err
+	}
+	next := ""
+	if len(rows) == query.Limit {
+		next = rows[len(rows)-1].ID
+	}
+	return rows, next, nil
+}
+
+type ReadMessage struct {
+	UserID string `binding:"required" json:"userID"`
+}
+
+// Read marks every message sent by UserID to me as read
+func (body *ReadMessage) Read(ctx context.Context, me string) (int64, error) {
+	return dao.MarkMessagesRead(ctx, me, body.UserID)
+}
@@ -1,7 +1,10 @@
 package dto
 
 import (
+	"app/lib"
+	"app/lib/patch"
 	"app/repository/dao"
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -17,20 +20,22 @@ type NewRole struct {
 	ActionID    string `binding:"omitempty" json:"actionID"`
 }
 
-func (body *NewRole) Create() (dao.Role, error) {
+func (body *NewRole) Create(ctx context.Context) (dao.Role, error) {
 	m := dao.Role{
 		Name: body.Name, Description: body.Description, IsDefault: body.IsDefault, Code: body.Code,
 	}
-	if body.ActionID != "" {
-		actions, err := dao.FindActions(map[string]interface{}{
-			"where": strings.Split(body.ActionID, ","),
-		})
-		if err != nil {
-			return m, err
+	err := dao.Transaction(ctx, func(ctx context.Context) (err error) {
+		var actions []dao.Action
+		if body.ActionID != "" {
+			actions, err = dao.Actions.FindAll(ctx, dao.NewQuery().WhereIDs(strings.Split(body.ActionID, ",")))
+			if err != nil {
+				return err
+			}
 		}
-		return m.Create(actions)
-	}
-	return m.Create(nil)
+		m, err = m.Create(ctx, actions)
+		return err
+	})
+	return m, err
 }
 
 type UpdateRole struct {
@@ -42,11 +47,11 @@ type UpdateRole struct {
 	ActionID    *string `binding:"omitempty" json:"actionID"`
 }
 
-func (body *UpdateRole) Save(id uint) (dao.Role, error) {
-	m, err := dao.FindRole(id, nil)
+func (body *UpdateRole) Save(ctx context.Context, id uint) (dao.Role, error) {
+	m, err := dao.Roles.Find(ctx, id, nil)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return m, errors.New("角色不存在")
+			return m, lib.ErrRoleNotFound
 		} else {
 			return m, err
 		}
@@ -66,15 +71,42 @@ func (body *UpdateRole) Save(id uint) (dao.Role, error) {
 	}
 	values = omitEmpty(values)
 	if body.ActionID != nil {
-		actions, err := dao.FindActions(map[string]interface{}{
-			"where": strings.Split(*body.ActionID, ","),
-		})
+		actions, err := dao.Actions.FindAll(ctx, dao.NewQuery().WhereIDs(strings.Split(*body.ActionID, ",")))
 		if err != nil {
 			return m, err
 		}
-		return m.Update(values, actions)
+		return m.Update(ctx, values, actions)
+	}
+	return m.Update(ctx, values, []dao.Action{})
+}
+
+// PatchRole holds the fields of a role a patch may change, its actions are
+// changed by role/action
+type PatchRole struct {
+	Name        string `binding:"omitempty,lt=200" json:"name"`
+	Description string `json:"description"`
+	Code        string `json:"code"`
+	IsDefault   bool   `json:"isDefault"`
+	IsActived   bool   `json:"isActived"`
+}
+
+// Patch applies p to the role of id
+func (body *PatchRole) Patch(ctx context.Context, id uint, p patch.Patch) (dao.Role, error) {
+	m, err := dao.Roles.Find(ctx, id, nil)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return m, lib.ErrRoleNotFound
+		}
+		return m, err
 	}
-	return m.Update(values, []dao.Action{})
+	*body = PatchRole{
+		Name: m.Name, Description: m.Description, Code: m.Code, IsDefault: m.IsDefault, IsActived: m.IsActived,
+	}
+	values, err := p.Apply(body)
+	if err != nil || len(values) == 0 {
+		return m, err
+	}
+	return m.Update(ctx, values, nil)
 }
 
 type QueryRole struct {
@@ -87,26 +119,20 @@ type QueryRole struct {
 	SortOrder string `form:"sortOrder,default=desc" binding:"oneof=asc desc" json:"sortOrder"`
 }
 
-func (query *QueryRole) Find() ([]dao.Role, int64, error) {
-	where := make([][]interface{}, 0)
+func (query *QueryRole) Find(ctx context.Context) ([]dao.Role, int64, error) {
+	q := dao.NewQuery().Preload("Actions")
 	if query.Key != "" {
-		where = append(where, []interface{}{"name LIKE ?", fmt.Sprintf("%%%s%%", query.Key)})
+		q.Where("name LIKE ?", fmt.Sprintf("%%%s%%", query.Key))
 	}
 	if query.IsDefault != nil {
 		isDefault := *query.IsDefault == 1
-		where = append(where, []interface{}{"is_default = ?", isDefault})
+		q.Where("is_default = ?", isDefault)
 	}
 	if query.IsActived != nil {
 		isActived := *query.IsActived == 1
-		where = append(where, []interface{}{"is_actived = ?", isActived})
+		q.Where("is_actived = ?", isActived)
 	}
-	return dao.FindAndCountRoles(map[string]interface{}{
-		"where":   where,
-		"preload": []string{"Actions"},
-		"offset":  (query.Page - 1) * query.Limit,
-		"limit":   query.Limit,
-		"order":   fmt.Sprintf("%s %s", query.SortBy, query.SortOrder),
-	})
+	return dao.Roles.FindAndCount(ctx, q.Order(fmt.Sprintf("%s %s", query.SortBy, query.SortOrder)).Page(query.Page, query.Limit))
 }
 
 type OPRole struct {
@@ -123,82 +149,83 @@ func isUserExist(row dao.User, rows []dao.User) bool {
 	return false
 }
 
-func (body OPRole) Grant() (err error) {
-	role, err := dao.FindRole(body.RoleID, map[string]interface{}{
-		"preload": []string{"Users"},
-	})
-	if err != nil {
-		return err
-	}
-	users, err := dao.FindUsers(map[string]interface{}{
-		"where": strings.Split(body.UserID, ","),
-	})
-	if err != nil {
-		return err
-	}
-	var next []dao.User
-	for _, user := range users {
-		if !isUserExist(user, role.Users) {
-			next = append(next, user)
+func (body OPRole) Grant(ctx context.Context) (err error) {
+	return dao.Transaction(ctx, func(ctx context.Context) error {
+		role, err := dao.Roles.Find(ctx, body.RoleID, dao.NewQuery().Preload("Users"))
+		if err != nil {
+			return dao.NotFoundAs(err, lib.ErrRoleNotFound)
 		}
-	}
-	return role.Relations("Users").Append(next)
+		users, err := findIDs(ctx, dao.Users, body.UserID, userID, lib.ErrUserNotFound)
+		if err != nil {
+			return err
+		}
+		var next []dao.User
+		for _, user := range users {
+			if !isUserExist(user, role.Users) {
+				next = append(next, user)
+			}
+		}
+		return role.Relations(ctx, "Users").Append(next)
+	})
 }
 
-func (body OPRole) Revoke() (err error) {
-	role, err := dao.FindRole(body.RoleID, map[string]interface{}{
-		"preload": []string{"Users"},
-	})
-	if err != nil {
-		return err
-	}
-	users, err := dao.FindUsers(map[string]interface{}{
-		"where": strings.Split(body.UserID, ","),
-	})
-	if err != nil {
-		return err
-	}
-	var next []dao.User
-	for _, user := range users {
-		if isUserExist(user, role.Users) {
-			next = append(next, user)
+func (body OPRole) Revoke(ctx context.Context) (err error) {
+	return dao.Transaction(ctx, func(ctx context.Context) error {
+		role, err := dao.Roles.Find(ctx, body.RoleID, dao.NewQuery().Preload("Users"))
+		if err != nil {
+			return dao.NotFoundAs(err, lib.ErrRoleNotFound)
 		}
-	}
-	return role.Relations("Users").Delete(next)
+		users, err := findIDs(ctx, dao.Users, body.UserID, userID, lib.ErrUserNotFound)
+		if err != nil {
+			return err
+		}
+		var next []dao.User
+		for _, user := range users {
+			if isUserExist(user, role.Users) {
+				next = append(next, user)
+			}
+		}
+		return role.Relations(ctx, "Users").Delete(next)
+	})
 }
 
-func (body OPRole) Change() (err error) {
-	var next []dao.User
-	role, err := dao.FindRole(body.RoleID, map[string]interface{}{
-		"preload": []string{"Users"},
-	})
-	if err != nil {
-		return err
-	}
-	users, err := dao.FindUsers(map[string]interface{}{
-		"where": strings.Split(body.UserID, ","),
+func (body OPRole) Change(ctx context.Context) (err error) {
+	return dao.Transaction(ctx, func(ctx context.Context) error {
+		var next []dao.User
+		role, err := dao.Roles.Find(ctx, body.RoleID, dao.NewQuery().Preload("Users"))
+		if err != nil {
+			return dao.NotFoundAs(err, lib.ErrRoleNotFound)
+		}
+		users, err := findIDs(ctx, dao.Users, body.UserID, userID, lib.ErrUserNotFound)
+		if err != nil {
+			return err
+		}
+		next = append(next, users...)
+		return role.Relations(ctx, "Users").Replace(next)
 	})
-	if err != nil {
-		return err
-	}
-	next = append(next, users...)
-	return role.Relations("Users").Replace(next)
 }
 
 type ToggleRoleActive struct {
 	RoleID string `binding:"required" json:"roleID"`
 }
 
-func (body ToggleRoleActive) Active() (err error) {
-	values := map[string]interface{}{
-		"is_actived": true,
-	}
-	return dao.UpdateRoles(values, strings.Split(body.RoleID, ","))
+func (body ToggleRoleActive) Active(ctx context.Context) (err error) {
+	return body.toggle(ctx, true)
 }
 
-func (body ToggleRoleActive) Deactive() (err error) {
+func (body ToggleRoleActive) Deactive(ctx context.Context) (err error) {
+	return body.toggle(ctx, false)
+}
+
+// toggle saves isActived of the roles of the comma separated RoleID, unknown ids fail with ErrRoleNotFound
+func (body ToggleRoleActive) toggle(ctx context.Context, isActived bool) error {
 	values := map[string]interface{}{
-		"is_actived": false,
+		"is_actived": isActived,
 	}
-	return dao.UpdateRoles(values, strings.Split(body.RoleID, ","))
+	return dao.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := findIDs(ctx, dao.Roles, body.RoleID, roleID, lib.ErrRoleNotFound); err != nil {
+			return err
+		}
+		return dao.UpdateRoles(ctx, values, strings.Split(body.RoleID, ","))
+	})
 }
@@ -2,56 +2,68 @@ package dto
 
 import (
 	"app/repository/dao"
+	"context"
 )
 
 type ToggleFollow struct {
 	UserID string `binding:"required" json:"userID"`
 }
 
-func (body ToggleFollow) Follow(id string) (dao.User, error) {
-	me, err := dao.FindUser(id, map[string]interface{}{
-		"preload": []string{"Followings"},
-	})
-	if err != nil {
-		return me, err
-	}
-	user, err := dao.FindUser(body.UserID, map[string]interface{}{
-		// "preload": []string{"Fans"},
+func (body ToggleFollow) Follow(ctx context.Context, id string) (dao.User, error) {
+	var me dao.User
+	err := dao.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		me, err = dao.Users.Find(ctx, id, dao.NewQuery().Preload("Followings"))
+		if err != nil {
+			return err
+		}
+		user, err := dao.Users.Find(ctx, body.UserID, nil)
+		if err != nil {
+			return err
+		}
+		next := make([]dao.User, 0)
+		for _, following := range me.Followings {
+			if following.ID != user.ID {
+				next = append(next, following)
+			}
+		}
+		// following twice would count the same fan twice
+		if len(next) == len(me.Followings) {
+			if err := me.Follow(ctx, user); err != nil {
+				return err
+			}
+		}
+		me.Followings = append(next, user)
+		return nil
 	})
-	if err != nil {
-		return me, err
-	}
-	next := make([]dao.User, 0)
-	for _, following := range me.Followings {
-		if following.ID != user.ID {
-			next = append(next, following)
-		}
-	}
-	me.Follow(user)
-	me.Followings = append(next, user)
-	return me, nil
+	return me, err
 }
 
-func (body ToggleFollow) Unfollow(id string) (dao.User, error) {
-	me, err := dao.FindUser(id, map[string]interface{}{
-		"preload": []string{"Followings"},
-	})
-	if err != nil {
-		return me, err
-	}
-	user, err := dao.FindUser(body.UserID, map[string]interface{}{
-		// "preload": []string{"Fans"},
+func (body ToggleFollow) Unfollow(ctx context.Context, id string) (dao.User, error) {
+	var me dao.User
+	err := dao.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		me, err = dao.Users.Find(ctx, id, dao.NewQuery().Preload("Followings"))
+		if err != nil {
+			return err
+		}
+		user, err := dao.Users.Find(ctx, body.UserID, nil)
+		if err != nil {
+			return err
+		}
+		next := make([]dao.User, 0)
+		for _, following := range me.Followings {
+			if following.ID != user.ID {
+				next = append(next, following)
+			}
+		}
+		if len(next) < len(me.Followings) {
+			if err := me.Unfollow(ctx, user); err != nil {
+				return err
+			}
+		}
+		me.Followings = next
+		return nil
 	})
-	if err != nil {
-		return me, err
-	}
-	next := make([]dao.User, 0)
-	for _, following := range me.Followings {
-		if following.ID != user.ID {
-			next = append(next, following)
-		}
-	}
-	me.Unfollow(user)
-	me.Followings = next
-	return me, nil
+	return me, err
 }
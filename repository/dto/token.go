@@ -0,0 +1,104 @@
+package dto
+
+import (
+	"app/lib"
+	"app/repository/dao"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IssueRefreshToken stores a refresh token starting a new family and returns the raw token
+func IssueRefreshToken(ctx context.Context, userID string, ttl time.Duration) (string, error) {
+	raw, hash, err := lib.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	m := dao.RefreshToken{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiredAt: lib.LocalTime{Time: time.Now().Add(ttl)},
+	}
+	if _, err := m.Create(ctx); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+type RefreshAuth struct {
+	RefreshToken string `binding:"required" json:"refreshToken"`
+}
+
+// Refresh rotates the refresh token and returns its owner with the next raw token
+func (body *RefreshAuth) Refresh(ctx context.Context, ttl time.Duration) (dao.User, string, error) {
+	var user dao.User
+	found, err := dao.FindRefreshTokenByHash(ctx, lib.HashToken(body.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, "", lib.ErrRefreshTokenMissing
+		} else {
+			return user, "", err
+		}
+	}
+	if found.IsRevoked() {
+		// a rotated token presented again means it was stolen, revoke the whole family
+		if found.ReplacedBy != "" {
+			if err := dao.RevokeRefreshTokenFamily(ctx, found.FamilyID); err != nil {
+				return user, "", err
+			}
+			return user, "", lib.ErrRefreshTokenReused
+		}
+		return user, "", lib.ErrRefreshTokenInvalid
+	}
+	if found.IsExpired() {
+		return user, "", lib.ErrRefreshTokenExpired
+	}
+	user, err = dao.Users.Find(ctx, found.UserID, nil)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, "", lib.ErrUserNotFound
+		} else {
+			return user, "", err
+		}
+	}
+	if !user.IsActived {
+		return user, "", lib.ErrUserInactive
+	}
+	raw, hash, err := lib.GenerateOpaqueToken()
+	if err != nil {
+		return user, "", err
+	}
+	_, err = found.Rotate(ctx, dao.RefreshToken{
+		TokenHash: hash,
+		ExpiredAt: lib.LocalTime{Time: time.Now().Add(ttl)},
+	})
+	if err != nil {
+		return user, "", err
+	}
+	return user, raw, nil
+}
+
+type Logout struct {
+	RefreshToken string `binding:"omitempty" json:"refreshToken"`
+}
+
+// Logout revokes the refresh token family of the given refresh token owned by user
+func (body *Logout) Logout(ctx context.Context, userID string) error {
+	if body.RefreshToken == "" {
+		return nil
+	}
+	found, err := dao.FindRefreshTokenByHash(ctx, lib.HashToken(body.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return lib.ErrRefreshTokenMissing
+		} else {
+			return err
+		}
+	}
+	if found.UserID != userID {
+		return lib.ErrRefreshTokenMissing
+	}
+	return dao.RevokeRefreshTokenFamily(ctx, found.FamilyID)
+}
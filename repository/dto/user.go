@@ -1,7 +1,10 @@
 package dto
 
 import (
+	"app/lib"
+	"app/lib/patch"
 	"app/repository/dao"
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -22,31 +25,25 @@ type QueryUser struct {
 	SortOrder  string  `form:"sortOrder,default=desc" binding:"oneof=asc desc" json:"sortOrder"`
 }
 
-func (query *QueryUser) Find() ([]dao.User, int64, error) {
-	where := make([][]interface{}, 0)
+func (query *QueryUser) Find(ctx context.Context) ([]dao.User, int64, error) {
+	q := dao.NewQuery().Preload("Role")
 	if query.Key != "" {
-		where = append(where, []interface{}{"username LIKE ?", fmt.Sprintf("%%%s%%", query.Key)})
+		q.Where("username LIKE ?", fmt.Sprintf("%%%s%%", query.Key))
 	}
 	if query.RoleID != nil {
-		where = append(where, []interface{}{"role_id = ?", query.RoleID})
+		q.Where("role_id = ?", query.RoleID)
 	}
 	if query.GroupID != nil {
-		where = append(where, []interface{}{"group_id = ?", query.GroupID})
+		q.Where("group_id = ?", query.GroupID)
 	}
 	if query.HasNoGroup != nil {
 		if *query.HasNoGroup == 1 {
-			where = append(where, []interface{}{"group_id IS NULL"})
+			q.Where("group_id IS NULL")
 		} else {
-			where = append(where, []interface{}{"group_id IS NOT NULL"})
+			q.Where("group_id IS NOT NULL")
 		}
 	}
-	return dao.FindAndCountUsers(map[string]interface{}{
-		"where":   where,
-		"preload": []string{"Role"},
-		"offset":  (query.Page - 1) * query.Limit,
-		"limit":   query.Limit,
-		"order":   fmt.Sprintf("%s %s", query.SortBy, query.SortOrder),
-	})
+	return dao.Users.FindAndCount(ctx, q.Order(fmt.Sprintf("%s %s", query.SortBy, query.SortOrder)).Page(query.Page, query.Limit))
 }
 
 type UpdateUser struct {
@@ -59,11 +56,11 @@ type UpdateUser struct {
 	IsActived bool   `binding:"omitempty" json:"isActived"`
 }
 
-func (body *UpdateUser) Save(id string) (dao.User, error) {
-	user, err := dao.FindUser(id, nil)
+func (body *UpdateUser) Save(ctx context.Context, id string) (dao.User, error) {
+	user, err := dao.Users.Find(ctx, id, nil)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return user, errors.New("用户不存在")
+			return user, lib.ErrUserNotFound
 		} else {
 			return user, err
 		}
@@ -78,7 +75,49 @@ func (body *UpdateUser) Save(id string) (dao.User, error) {
 		"is_actived": body.IsActived,
 	}
 	values = omitEmpty(values)
-	return user.Update(values)
+	return user.Update(ctx, values)
+}
+
+// PatchUser holds the fields of a user a patch may change
+type PatchUser struct {
+	Email     string `binding:"omitempty,lt=200,email" json:"email"`
+	Avatar    string `binding:"omitempty,url" json:"avatar"`
+	Memo      string `json:"memo"`
+	Nickname  string `json:"nickname"`
+	Gender    string `json:"gender"`
+	Phone     string `json:"phone"`
+	IsActived bool   `json:"isActived"`
+}
+
+// Patch applies p to the user of id, fields it sets to false or empty are saved too
+func (body *PatchUser) Patch(ctx context.Context, id string, p patch.Patch) (dao.User, error) {
+	user, err := dao.Users.Find(ctx, id, nil)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, lib.ErrUserNotFound
+		}
+		return user, err
+	}
+	*body = PatchUser{
+		Email: user.Email, Avatar: user.Avatar, Memo: user.Memo, Nickname: user.Nickname,
+		Gender: user.Gender, Phone: user.Phone, IsActived: user.IsActived,
+	}
+	values, err := p.Apply(body)
+	if err != nil || len(values) == 0 {
+		return user, err
+	}
+	// deactivating signs the user out everywhere like deactive/user
+	err = dao.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = user.Update(ctx, values); err != nil {
+			return err
+		}
+		if _, changed := values["IsActived"]; changed && !body.IsActived {
+			return dao.InvalidateUserTokens(ctx, []string{user.ID})
+		}
+		return nil
+	})
+	return user, err
 }
 
 type RegisterUser struct {
@@ -88,14 +127,35 @@ type RegisterUser struct {
 	Email          string `binding:"lt=200,email" json:"email"`
 }
 
-func (body *RegisterUser) Create(roleID uint) (dao.User, error) {
+// Create registers the user with the role of roleID. The check of the username
+// only gives a friendly error, concurrent registrations of the same username can
+// both pass it and the unique index of users.username rejects the second insert.
+func (body *RegisterUser) Create(ctx context.Context, roleID uint) (dao.User, error) {
 	user := dao.User{
 		Username: body.Username,
 		Email:    body.Email,
 		Password: body.Password,
 		RoleID:   &roleID,
 	}
-	return user.Create()
+	err := dao.Transaction(ctx, func(ctx context.Context) error {
+		if exists, _, err := dao.Users.ExistsBy(ctx, "username", body.Username); err != nil {
+			return err
+		} else if exists {
+			return lib.ErrUserExists
+		}
+		if exists, _, err := dao.Roles.Exists(ctx, roleID); err != nil {
+			return err
+		} else if !exists {
+			return lib.ErrRoleNotFound
+		}
+		var err error
+		user, err = user.Create(ctx)
+		return err
+	})
+	if dao.IsUniqueViolation(err) {
+		return user, lib.ErrUserExists
+	}
+	return user, err
 }
 
 type LoginUser struct {
@@ -103,25 +163,25 @@ type LoginUser struct {
 	Password string `binding:"required,lt=200" json:"password"`
 }
 
-func (body *LoginUser) Login(roleID uint) (dao.User, error) {
-	exists, found := dao.FindByUsername(body.Username)
+func (body *LoginUser) Login(ctx context.Context, roleID uint) (dao.User, error) {
+	exists, found, err := dao.Users.ExistsBy(ctx, "username", body.Username)
+	if err != nil {
+		return found, err
+	}
 	if !exists {
-		return found, errors.New("用户不存在")
+		return found, lib.ErrUserNotFound
 	}
 	if !found.IsActived {
-		return found, errors.New("用户未激活")
+		return found, lib.ErrUserInactive
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(found.Password), []byte(body.Password)); err != nil {
-		return found, errors.New("密码不正确")
-	}
-	updated, err := found.Update(map[string]interface{}{"last_logined_at": time.Now()})
-	if err != nil {
-		return updated, err
+		return found, lib.ErrPasswordWrong
 	}
+	values := map[string]interface{}{"last_logined_at": time.Now()}
 	if found.RoleID == nil {
-		return found.Update(map[string]interface{}{"role_id": roleID})
+		values["role_id"] = roleID
 	}
-	return updated, nil
+	return found.Update(ctx, values)
 }
 
 type ChangePassword struct {
@@ -130,26 +190,53 @@ type ChangePassword struct {
 	RepeatPassword string `binding:"required,lt=200" json:"repeatPassword"`
 }
 
-func (body *ChangePassword) ChangePassword(id string) (dao.User, error) {
-	user, err := dao.FindUser(id, nil)
+func (body *ChangePassword) ChangePassword(ctx context.Context, id string) (dao.User, error) {
+	user, err := dao.Users.Find(ctx, id, nil)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return user, errors.New("用户不存在")
+			return user, lib.ErrUserNotFound
 		} else {
 			return user, err
 		}
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.OldPassword)); err != nil {
-		return user, errors.New("旧密码不正确")
+		return user, lib.ErrOldPasswordWrong
 	}
 	if body.NewPassword != body.RepeatPassword {
-		return user, errors.New("重复密码不匹配")
+		return user, lib.ErrPasswordMismatch
 	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), 4)
 	if err != nil {
 		return user, err
 	}
-	return user.Update(map[string]interface{}{"password": string(hashedPassword)})
+	var updated dao.User
+	err = dao.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		updated, err = user.Update(ctx, map[string]interface{}{"password": string(hashedPassword)})
+		if err != nil {
+			return err
+		}
+		return dao.InvalidateUserTokens(ctx, []string{user.ID})
+	})
+	return updated, err
+}
+
+// ChangeLocale sets the locale replied to the user whatever Accept-Language says,
+// an empty locale follows Accept-Language again
+type ChangeLocale struct {
+	Locale string `binding:"omitempty,oneof=zh en" json:"locale"`
+}
+
+func (body *ChangeLocale) ChangeLocale(ctx context.Context, id string) (dao.User, error) {
+	user, err := dao.Users.Find(ctx, id, nil)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, lib.ErrUserNotFound
+		} else {
+			return user, err
+		}
+	}
+	return user.Update(ctx, map[string]interface{}{"locale": body.Locale})
 }
 
 type ResetPassword struct {
@@ -157,39 +244,64 @@ type ResetPassword struct {
 	RepeatPassword string `binding:"required,lt=200" json:"repeatPassword"`
 }
 
-func (body *ResetPassword) ResetPassword(id string) (dao.User, error) {
-	user, err := dao.FindUser(id, nil)
+func (body *ResetPassword) ResetPassword(ctx context.Context, id string) (dao.User, error) {
+	user, err := dao.Users.Find(ctx, id, nil)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return user, errors.New("用户不存在")
+			return user, lib.ErrUserNotFound
 		} else {
 			return user, err
 		}
 	}
 	if body.NewPassword != body.RepeatPassword {
-		return user, errors.New("重复密码不匹配")
+		return user, lib.ErrPasswordMismatch
 	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), 4)
 	if err != nil {
 		return user, err
 	}
-	return user.Update(map[string]interface{}{"password": string(hashedPassword)})
+	var updated dao.User
+	err = dao.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		updated, err = user.Update(ctx, map[string]interface{}{"password": string(hashedPassword)})
+		if err != nil {
+			return err
+		}
+		return dao.InvalidateUserTokens(ctx, []string{user.ID})
+	})
+	return updated, err
 }
 
 type ToggleUserActive struct {
 	UserID string `binding:"required" json:"userID"`
 }
 
-func (body ToggleUserActive) Active() (err error) {
+// Active activates the users of the comma separated UserID, unknown ids fail with ErrUserNotFound
+func (body ToggleUserActive) Active(ctx context.Context) (err error) {
 	values := map[string]interface{}{
 		"is_actived": true,
 	}
-	return dao.UpdateUsers(values, strings.Split(body.UserID, ","))
+	return dao.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := findIDs(ctx, dao.Users, body.UserID, userID, lib.ErrUserNotFound); err != nil {
+			return err
+		}
+		return dao.Users.UpdateAll(ctx, values, strings.Split(body.UserID, ","))
+	})
 }
 
-func (body ToggleUserActive) Deactive() (err error) {
+// Deactive deactivates the users of the comma separated UserID and invalidates their tokens
+func (body ToggleUserActive) Deactive(ctx context.Context) (err error) {
 	values := map[string]interface{}{
 		"is_actived": false,
 	}
-	return dao.UpdateUsers(values, strings.Split(body.UserID, ","))
+	ids := strings.Split(body.UserID, ",")
+	return dao.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := findIDs(ctx, dao.Users, body.UserID, userID, lib.ErrUserNotFound); err != nil {
+			return err
+		}
+		if err := dao.Users.UpdateAll(ctx, values, ids); err != nil {
+			return err
+		}
+		return dao.InvalidateUserTokens(ctx, ids)
+	})
 }
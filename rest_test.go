@@ -0,0 +1,72 @@
+package main
+
+import (
+	"app/lib"
+	"app/lib/config"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCreatedLocation(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	reply := call(t, "POST", "role", adminToken, map[string]string{"name": unique("role")})
+	location := reply.header.Get("Location")
+	if reply.status != http.StatusCreated || location == "" {
+		t.Fatalf("create replied %d at %q", reply.status, location)
+	}
+	var role struct {
+		ID uint `json:"id"`
+	}
+	ok(t, "GET", location[len("/api/v1/"):], "", nil, &role)
+	if want := fmt.Sprintf("/api/v1/public/role/%d", role.ID); location != want {
+		t.Errorf("created role is at %s want %s", location, want)
+	}
+}
+
+func TestNoContent(t *testing.T) {
+	adminToken := login(t, newUser(t, adminRoleID))
+	user := newUser(t, memberRoleID)
+	reply := call(t, "DELETE", "user/"+user.ID, adminToken, nil)
+	if reply.status != http.StatusNoContent || len(reply.Data) != 0 {
+		t.Fatalf("delete replied %d %s", reply.status, reply.Data)
+	}
+}
+
+func TestValidationStatus(t *testing.T) {
+	reply := fails(t, lib.ErrValidation, "POST", "public/register", "", map[string]string{"username": ""})
+	if reply.status != http.StatusUnprocessableEntity {
+		t.Fatalf("validation replied %d", reply.status)
+	}
+}
+
+func TestUnknownRoute(t *testing.T) {
+	fails(t, lib.ErrRouteNotFound, "GET", "missing", "", nil)
+	reply := fails(t, lib.ErrMethodNotAllowed, "PATCH", "public/login", "", nil)
+	if allow := reply.header.Get("Allow"); allow != "POST" {
+		t.Errorf("Allow is %q", allow)
+	}
+}
+
+func TestLegacyEnvelope(t *testing.T) {
+	config.App.LegacyEnvelope = true
+	defer func() { config.App.LegacyEnvelope = false }()
+
+	adminToken := login(t, newUser(t, adminRoleID))
+	reply := call(t, "POST", "role", adminToken, map[string]string{"name": unique("role")})
+	if reply.status != http.StatusOK || reply.Code != 0 || len(reply.Data) == 0 {
+		t.Fatalf("legacy create replied %d %d %s", reply.status, reply.Code, reply.Data)
+	}
+	user := newUser(t, memberRoleID)
+	if reply := call(t, "DELETE", "user/"+user.ID, adminToken, nil); reply.status != http.StatusOK {
+		t.Errorf("legacy delete replied %d", reply.status)
+	}
+	reply = fails(t, lib.ErrValidation, "POST", "public/register", "", map[string]string{"username": ""})
+	if fields, ok := reply.Msg.(map[string]interface{}); !ok || fields["username"] == nil {
+		t.Errorf("legacy validation replied msg %v", reply.Msg)
+	}
+	fails(t, lib.ErrUserNotFound, "POST", "public/login", "", map[string]string{"username": unique("nobody"), "password": "secret"})
+	if reply := call(t, "GET", "ping", "", nil); reply.status != http.StatusOK || reply.Code != 0 {
+		t.Errorf("legacy ping replied %d %d", reply.status, reply.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"app/lib"
+	"app/repository/dao"
+	"context"
+	"testing"
+)
+
+func TestFollowAndUnfollowCounters(t *testing.T) {
+	fan, star := newUser(t, memberRoleID), newUser(t, memberRoleID)
+	token := login(t, fan)
+	target := map[string]string{"userID": star.ID}
+
+	assertCounters := func(following, fans uint) {
+		t.Helper()
+		if got := findUser(t, fan.ID).FollowingAmount; got != following {
+			t.Fatalf("fan follows %d want %d", got, following)
+		}
+		if got := findUser(t, star.ID).FansAmount; got != fans {
+			t.Fatalf("star has %d fans want %d", got, fans)
+		}
+	}
+
+	var me struct {
+		Followings []struct {
+			ID string `json:"id"`
+		} `json:"followings"`
+	}
+	ok(t, "POST", "follow/user", token, target, &me)
+	if len(me.Followings) != 1 || me.Followings[0].ID != star.ID {
+		t.Fatalf("followings are %v want %s", me.Followings, star.ID)
+	}
+	assertCounters(1, 1)
+
+	// following twice keeps a single fan
+	ok(t, "POST", "follow/user", token, target, nil)
+	assertCounters(1, 1)
+
+	var fans struct {
+		Fans []struct {
+			ID string `json:"id"`
+		} `json:"fans"`
+	}
+	ok(t, "GET", "user/"+star.ID+"/fans", token, nil, &fans)
+	if len(fans.Fans) != 1 || fans.Fans[0].ID != fan.ID {
+		t.Fatalf("fans are %v want %s", fans.Fans, fan.ID)
+	}
+
+	ok(t, "DELETE", "follow/user", token, target, &me)
+	if len(me.Followings) != 0 {
+		t.Fatalf("unfollowed user still follows %v", me.Followings)
+	}
+	assertCounters(0, 0)
+
+	// unfollowing twice never goes below zero
+	ok(t, "DELETE", "follow/user", token, target, nil)
+	assertCounters(0, 0)
+
+	fails(t, lib.ErrNotFound, "POST", "follow/user", token, map[string]string{"userID": "missing"})
+}
+
+func TestConversationCursor(t *testing.T) {
+	me, friend, other := newUser(t, memberRoleID), newUser(t, memberRoleID), newUser(t, memberRoleID)
+	token := login(t, me)
+	send := func(to string) dao.Message {
+		t.Helper()
+		m, err := dao.Message{SenderID: me.ID, RecipientID: to, Content: "hi"}.Create(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	mine, elsewhere := send(friend.ID), send(other.ID)
+
+	ok(t, "GET", "message/user/"+friend.ID+"?cursor="+mine.ID, token, nil, nil)
+	fails(t, lib.ErrMessageNotFound, "GET", "message/user/"+friend.ID+"?cursor="+elsewhere.ID, token, nil)
+}
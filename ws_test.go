@@ -0,0 +1,261 @@
+package main
+
+import (
+	"app/lib"
+	"app/lib/ws"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialSocket opens the websocket of token on srv
+func dialSocket(srv *httptest.Server, token string, header http.Header) (*websocket.Conn, *http.Response, error) {
+	u := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/public/message?token=" + url.QueryEscape(token)
+	return websocket.DefaultDialer.Dial(u, header)
+}
+
+// openSocket opens a websocket of user and waits until the manager registered it
+func openSocket(t *testing.T, srv *httptest.Server, user string, token string) *websocket.Conn {
+	t.Helper()
+	before := len(ws.WebsocketManager.FindClients(user))
+	conn, _, err := dialSocket(srv, token, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	eventually(t, "connection registered", func() bool {
+		return len(ws.WebsocketManager.FindClients(user)) > before
+	})
+	return conn
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func emit(t *testing.T, conn *websocket.Conn, msg ws.Message) {
+	t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receive reads the next message of conn
+func receive(t *testing.T, conn *websocket.Conn) ws.Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+	var msg ws.Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// expect reads conn until a message matching event and channel arrives, the presence
+// of users of other tests is skipped
+func expect(t *testing.T, conn *websocket.Conn, event ws.Event, channel string) ws.Message {
+	t.Helper()
+	for {
+		if msg := receive(t, conn); msg.Event == event && msg.Channel == channel {
+			return msg
+		}
+	}
+}
+
+// expectPresence reads conn until key goes online or offline
+func expectPresence(t *testing.T, conn *websocket.Conn, event ws.Event, key string) {
+	t.Helper()
+	for {
+		if msg := expect(t, conn, event, ""); msg.Data == key {
+			return
+		}
+	}
+}
+
+// expectFail reads the fail event and checks the reason of its envelope
+func expectFail(t *testing.T, conn *websocket.Conn, event ws.Event, channel string, want *lib.AppError) {
+	t.Helper()
+	msg := expect(t, conn, event, channel)
+	data, _ := msg.Data.(map[string]any)
+	if data["reason"] != want.Reason {
+		t.Fatalf("%s on %q replied %v want %s", event, channel, msg.Data, want.Reason)
+	}
+}
+
+func TestSocketRejectsUnauthorized(t *testing.T) {
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	user := newUser(t, memberRoleID)
+
+	if _, resp, err := dialSocket(srv, "", nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("missing token opened the socket: %v %v", resp, err)
+	}
+
+	revoked := login(t, user)
+	ok(t, "POST", "logout", revoked, map[string]string{}, nil)
+	if _, resp, err := dialSocket(srv, revoked, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("revoked token opened the socket: %v %v", resp, err)
+	}
+
+	header := http.Header{"Origin": {"http://evil.example.com"}}
+	if _, resp, err := dialSocket(srv, login(t, user), header); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("foreign origin opened the socket: %v %v", resp, err)
+	}
+	if len(ws.WebsocketManager.FindClients(user.ID)) != 0 {
+		t.Fatal("rejected sockets were registered")
+	}
+}
+
+func TestSocketSendsToEveryConnection(t *testing.T) {
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	sender, recipient := newUser(t, memberRoleID), newUser(t, memberRoleID)
+	token := login(t, recipient)
+	first := openSocket(t, srv, recipient.ID, token)
+	second := openSocket(t, srv, recipient.ID, token)
+	conn := openSocket(t, srv, sender.ID, login(t, sender))
+
+	emit(t, conn, ws.Message{Event: ws.MessageEvent, Data: map[string]string{"to": recipient.ID, "content": "hello"}})
+	expect(t, conn, ws.MessageSentEvent, "")
+	for _, c := range []*websocket.Conn{first, second} {
+		msg := expect(t, c, ws.MessageEvent, "")
+		if msg.From != sender.ID {
+			t.Fatalf("message from %s want %s", msg.From, sender.ID)
+		}
+	}
+}
+
+func TestSocketChannelPermissions(t *testing.T) {
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	user, other := newUser(t, memberRoleID), newUser(t, memberRoleID)
+	group := newGroup(t, &other)
+	conn := openSocket(t, srv, user.ID, login(t, user))
+
+	mine, theirs, groupChannel := "user:"+user.ID, "user:"+other.ID, "group:"+group.ID
+	emit(t, conn, ws.Message{Event: ws.SubscribeEvent, Channel: theirs})
+	expectFail(t, conn, ws.SubscribeFailEvent, theirs, lib.ErrChannelDenied)
+	emit(t, conn, ws.Message{Event: ws.SubscribeEvent, Channel: groupChannel})
+	expectFail(t, conn, ws.SubscribeFailEvent, groupChannel, lib.ErrChannelDenied)
+	emit(t, conn, ws.Message{Event: ws.PublishEvent, Channel: groupChannel, Data: "hi"})
+	expectFail(t, conn, ws.PublishFailEvent, groupChannel, lib.ErrChannelDenied)
+	emit(t, conn, ws.Message{Event: ws.PublishEvent, Channel: theirs, Data: "hi"})
+	expectFail(t, conn, ws.PublishFailEvent, theirs, lib.ErrUserChannelPublish)
+
+	// users only listen to their own channel
+	emit(t, conn, ws.Message{Event: ws.SubscribeEvent, Channel: mine})
+	expect(t, conn, ws.SubscribedEvent, mine)
+	emit(t, conn, ws.Message{Event: ws.PublishEvent, Channel: mine, Data: "hi"})
+	expectFail(t, conn, ws.PublishFailEvent, mine, lib.ErrUserChannelPublish)
+}
+
+func TestSocketSlowClient(t *testing.T) {
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	slow, other := newUser(t, memberRoleID), newUser(t, memberRoleID)
+	// the slow client never reads what it is sent
+	openSocket(t, srv, slow.ID, login(t, slow))
+
+	data := strings.Repeat("x", 16*1024)
+	start := time.Now()
+	for i := 0; i < 2000; i++ {
+		ws.WebsocketManager.SendTo(&ws.Message{Event: ws.MessageEvent, Data: data}, slow.ID)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("sending to a slow client took %s", elapsed)
+	}
+	// it is disconnected once its queue is full and the manager keeps serving others
+	eventually(t, "slow client disconnected", func() bool {
+		return len(ws.WebsocketManager.FindClients(slow.ID)) == 0
+	})
+	conn := openSocket(t, srv, other.ID, login(t, other))
+	emit(t, conn, ws.Message{Event: ws.StatusEvent, Data: []string{other.ID}})
+	result := expect(t, conn, ws.StatusResultEvent, "")
+	if online, _ := result.Data.(map[string]any); online[other.ID] != true {
+		t.Fatalf("status replied %v", result.Data)
+	}
+}
+
+// the tests run with the LocalBroker of a single node
+func TestSocketPresenceAndChannels(t *testing.T) {
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	owner, member := newUser(t, memberRoleID), newUser(t, memberRoleID)
+	group := newGroup(t, &owner)
+	ok(t, "POST", "group/user", login(t, owner), map[string]string{"groupID": group.ID, "userID": member.ID}, nil)
+	conn := openSocket(t, srv, owner.ID, login(t, owner))
+
+	other := openSocket(t, srv, member.ID, login(t, member))
+	expectPresence(t, conn, ws.OnlineEvent, member.ID)
+	emit(t, conn, ws.Message{Event: ws.StatusEvent, Data: []string{member.ID}})
+	if result, _ := expect(t, conn, ws.StatusResultEvent, "").Data.(map[string]any); result[member.ID] != true {
+		t.Fatalf("status of %s is %v", member.ID, result)
+	}
+
+	channel := "group:" + group.ID
+	for _, c := range []*websocket.Conn{conn, other} {
+		emit(t, c, ws.Message{Event: ws.SubscribeEvent, Channel: channel})
+		expect(t, c, ws.SubscribedEvent, channel)
+	}
+	emit(t, conn, ws.Message{Event: ws.PublishEvent, Channel: channel, Data: "hello"})
+	for _, c := range []*websocket.Conn{conn, other} {
+		if msg := expect(t, c, ws.PublishEvent, channel); msg.From != owner.ID || msg.Data != "hello" {
+			t.Fatalf("published %+v", msg)
+		}
+	}
+
+	other.Close()
+	expectPresence(t, conn, ws.OfflineEvent, member.ID)
+	if ws.WebsocketManager.Contains(member.ID) {
+		t.Fatalf("%s is still online", member.ID)
+	}
+}
+
+func TestSocketEvictsGroupLeavers(t *testing.T) {
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	owner, member := newUser(t, memberRoleID), newUser(t, memberRoleID)
+	group := newGroup(t, &owner)
+	ownerToken := login(t, owner)
+	io := map[string]string{"groupID": group.ID, "userID": member.ID}
+	ok(t, "POST", "group/user", ownerToken, io, nil)
+	conn := openSocket(t, srv, owner.ID, ownerToken)
+	other := openSocket(t, srv, member.ID, login(t, member))
+	channel := "group:" + group.ID
+	for _, c := range []*websocket.Conn{conn, other} {
+		emit(t, c, ws.Message{Event: ws.SubscribeEvent, Channel: channel})
+		expect(t, c, ws.SubscribedEvent, channel)
+	}
+
+	ok(t, "DELETE", "group/user", login(t, member), io, nil)
+	expect(t, other, ws.UnsubscribedEvent, channel)
+	emit(t, conn, ws.Message{Event: ws.PublishEvent, Channel: channel, Data: "after"})
+	expect(t, conn, ws.PublishEvent, channel)
+	emit(t, other, ws.Message{Event: ws.StatusEvent, Data: []string{owner.ID}})
+	for {
+		msg := receive(t, other)
+		if msg.Event == ws.StatusResultEvent {
+			break
+		}
+		if msg.Event == ws.PublishEvent {
+			t.Fatalf("%s still receives %s after leaving", member.ID, channel)
+		}
+	}
+
+	// deleting the group evicts everyone left
+	ok(t, "DELETE", "group", ownerToken, map[string]string{"id": group.ID}, nil)
+	expect(t, conn, ws.UnsubscribedEvent, channel)
+}